@@ -0,0 +1,489 @@
+package common
+
+import (
+	"io"
+	"strings"
+	"sync"
+)
+
+//Aggregate accumulates the values of a group into a single result.
+type Aggregate interface {
+	Init()
+	Accumulate(value interface{}) error
+	Merge(other Aggregate) error
+	Result() interface{}
+}
+
+//AggregateRegistry holds the aggregate functions available to AggregateCommon. Names are case insensitive.
+type AggregateRegistry struct {
+	mutex      sync.RWMutex
+	aggregates map[string]func() Aggregate
+}
+
+//NewAggregateRegistry creates an AggregateRegistry holding the built-in aggregates.
+func NewAggregateRegistry() *AggregateRegistry {
+	registry := &AggregateRegistry{aggregates: map[string]func() Aggregate{}}
+	for name, factory := range map[string]func() Aggregate{
+		"COUNT": func() Aggregate { return &countAggregate{} },
+		"SUM":   func() Aggregate { return &sumAggregate{} },
+		"AVG":   func() Aggregate { return &avgAggregate{} },
+		"MIN": func() Aggregate {
+			return &extremeAggregate{operator: "MIN", keep: func(result int) bool { return result < 0 }}
+		},
+		"MAX": func() Aggregate {
+			return &extremeAggregate{operator: "MAX", keep: func(result int) bool { return result > 0 }}
+		},
+	} {
+		if err := registry.Register(name, factory); err != nil {
+			panic(err)
+		}
+	}
+	return registry
+}
+
+//Register makes an aggregate function available to the aggregates using the registry.
+func (r *AggregateRegistry) Register(name string, factory func() Aggregate) error {
+	name = strings.ToUpper(name)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.aggregates[name]; ok {
+		return DuplicateFunctionError{name}
+	}
+
+	r.aggregates[name] = factory
+	return nil
+}
+
+//New creates an initialized instance of the named aggregate. A distinct aggregate
+//only accumulates each non-NULL value once.
+func (r *AggregateRegistry) New(name string, distinct bool) (Aggregate, error) {
+	r.mutex.RLock()
+	factory, ok := r.aggregates[strings.ToUpper(name)]
+	r.mutex.RUnlock()
+
+	if !ok {
+		return nil, UnknownFunctionError{strings.ToUpper(name)}
+	}
+
+	aggregate := factory()
+	if distinct {
+		aggregate = &distinctAggregate{aggregate: aggregate}
+	}
+
+	aggregate.Init()
+	return aggregate, nil
+}
+
+//DefaultAggregates is the registry used by aggregates that are not bound to another one with Bind.
+var DefaultAggregates = NewAggregateRegistry()
+
+//RegisterAggregate registers an aggregate function in DefaultAggregates.
+func RegisterAggregate(name string, factory func() Aggregate) error {
+	return DefaultAggregates.Register(name, factory)
+}
+
+//NewAggregate creates an initialized instance of the named aggregate of DefaultAggregates.
+func NewAggregate(name string, distinct bool) (Aggregate, error) {
+	return DefaultAggregates.New(name, distinct)
+}
+
+type countAggregate struct {
+	count int64
+}
+
+func (c *countAggregate) Init() {
+	c.count = 0
+}
+
+func (c *countAggregate) Accumulate(value interface{}) error {
+	if value != nil {
+		c.count++
+	}
+	return nil
+}
+
+func (c *countAggregate) Merge(other Aggregate) error {
+	o, ok := other.(*countAggregate)
+	if !ok {
+		return AggregateMergeError{c, other}
+	}
+
+	c.count += o.count
+	return nil
+}
+
+func (c *countAggregate) Result() interface{} {
+	return c.count
+}
+
+type sumAggregate struct {
+	sum interface{}
+}
+
+func (s *sumAggregate) Init() {
+	s.sum = nil
+}
+
+func (s *sumAggregate) Accumulate(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	if s.sum == nil {
+		if !isNumeric(value) {
+			return newOperatorTypeError("SUM", value)
+		}
+		s.sum = value
+		return nil
+	}
+
+	sum, err := arithmetic("+", s.sum, value)
+	if err != nil {
+		return newOperatorTypeError("SUM", value)
+	}
+
+	s.sum = sum
+	return nil
+}
+
+func (s *sumAggregate) Merge(other Aggregate) error {
+	o, ok := other.(*sumAggregate)
+	if !ok {
+		return AggregateMergeError{s, other}
+	}
+	return s.Accumulate(o.sum)
+}
+
+func (s *sumAggregate) Result() interface{} {
+	return s.sum
+}
+
+type avgAggregate struct {
+	sum   float64
+	count int64
+}
+
+func (a *avgAggregate) Init() {
+	a.sum, a.count = 0, 0
+}
+
+func (a *avgAggregate) Accumulate(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+	case int64:
+		a.sum += float64(v)
+		a.count++
+	case float64:
+		a.sum += v
+		a.count++
+	default:
+		return newOperatorTypeError("AVG", value)
+	}
+	return nil
+}
+
+func (a *avgAggregate) Merge(other Aggregate) error {
+	o, ok := other.(*avgAggregate)
+	if !ok {
+		return AggregateMergeError{a, other}
+	}
+
+	a.sum += o.sum
+	a.count += o.count
+	return nil
+}
+
+func (a *avgAggregate) Result() interface{} {
+	if a.count == 0 {
+		return nil
+	}
+	return a.sum / float64(a.count)
+}
+
+//extremeAggregate implements MIN and MAX, keeping a value when keep accepts its comparison against the current result.
+type extremeAggregate struct {
+	operator string
+	keep     func(int) bool
+	value    interface{}
+}
+
+func (e *extremeAggregate) Init() {
+	e.value = nil
+}
+
+func (e *extremeAggregate) Accumulate(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	if e.value == nil {
+		e.value = value
+		return nil
+	}
+
+	result, err := compareValues(e.operator, value, e.value)
+	if err != nil {
+		return err
+	}
+
+	if e.keep(result) {
+		e.value = value
+	}
+	return nil
+}
+
+func (e *extremeAggregate) Merge(other Aggregate) error {
+	o, ok := other.(*extremeAggregate)
+	if !ok || o.operator != e.operator {
+		return AggregateMergeError{e, other}
+	}
+	return e.Accumulate(o.value)
+}
+
+func (e *extremeAggregate) Result() interface{} {
+	return e.value
+}
+
+//distinctAggregate accumulates each distinct value once. Values are keyed by hashKey, and the first
+//value seen for each key is kept so that merging accumulates it with its original type.
+type distinctAggregate struct {
+	aggregate Aggregate
+	seen      map[interface{}]interface{}
+}
+
+func (d *distinctAggregate) Init() {
+	d.aggregate.Init()
+	d.seen = map[interface{}]interface{}{}
+}
+
+func (d *distinctAggregate) Accumulate(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	key := hashKey(value)
+	if _, ok := d.seen[key]; ok {
+		return nil
+	}
+
+	d.seen[key] = value
+	return d.aggregate.Accumulate(value)
+}
+
+func (d *distinctAggregate) Merge(other Aggregate) error {
+	o, ok := other.(*distinctAggregate)
+	if !ok {
+		return AggregateMergeError{d, other}
+	}
+
+	for _, value := range o.seen {
+		if err := d.Accumulate(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *distinctAggregate) Result() interface{} {
+	return d.aggregate.Result()
+}
+
+//AggregateCommon represents an aggregate function call such as COUNT(*) or SUM(DISTINCT x). Once the
+//rows are grouped it evaluates to the aggregate result stored in the symbols under Key. The aggregate
+//function comes from DefaultAggregates unless the expression is bound to another registry.
+type AggregateCommon struct {
+	name       string
+	argument   Expression
+	distinct   bool
+	aggregates *AggregateRegistry
+}
+
+//NewAggregateCommon creates an instance of AggregateCommon. A nil argument stands for `*'.
+func NewAggregateCommon(name string, argument Expression, distinct bool) *AggregateCommon {
+	return &AggregateCommon{name: strings.ToUpper(name), argument: argument, distinct: distinct}
+}
+
+//newAggregate creates the aggregate function from the registry of the expression.
+func (a AggregateCommon) newAggregate() (Aggregate, error) {
+	if a.aggregates == nil {
+		return DefaultAggregates.New(a.name, a.distinct)
+	}
+	return a.aggregates.New(a.name, a.distinct)
+}
+
+//Key returns the symbol under which the aggregate result is stored in a grouped row.
+func (a AggregateCommon) Key() string {
+	return a.String()
+}
+
+func (a AggregateCommon) Evaluate(symbols map[string]interface{}) interface{} {
+	return mustEvaluate(a, symbols)
+}
+
+func (a AggregateCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	key := a.Key()
+
+	if value, ok := symbols[key]; ok {
+		return value, nil
+	}
+
+	return nil, UnknownIdentifierError{key}
+}
+
+func (a AggregateCommon) Children() []Expression {
+	if a.argument == nil {
+		return nil
+	}
+	return []Expression{a.argument}
+}
+
+func (a AggregateCommon) accumulate(aggregate Aggregate, row map[string]interface{}) error {
+	if a.argument == nil {
+		return aggregate.Accumulate(true)
+	}
+
+	value, err := a.argument.EvaluateE(row)
+	if err != nil {
+		return err
+	}
+	return aggregate.Accumulate(value)
+}
+
+type group struct {
+	row        map[string]interface{}
+	aggregates []Aggregate
+}
+
+//GroupRows consumes rows and returns one row per distinct combination of the groupBy columns. Each
+//output row holds the grouped columns and the result of every aggregate under its Key. Without groupBy
+//columns all rows form a single group, which exists even when there are no rows.
+func GroupRows(rows RowIterator, groupBy []GroupBySelect, aggregateCommons []*AggregateCommon) ([]map[string]interface{}, error) {
+	groups := map[string]*group{}
+	order := []*group{}
+
+	newGroup := func(row map[string]interface{}) (*group, error) {
+		g := &group{row: row, aggregates: make([]Aggregate, len(aggregateCommons))}
+		for i, aggregateCommon := range aggregateCommons {
+			aggregate, err := aggregateCommon.newAggregate()
+			if err != nil {
+				return nil, err
+			}
+			g.aggregates[i] = aggregate
+		}
+		order = append(order, g)
+		return g, nil
+	}
+
+	if len(groupBy) == 0 {
+		g, err := newGroup(map[string]interface{}{})
+		if err != nil {
+			return nil, err
+		}
+		groups[""] = g
+	}
+
+	for {
+		row, err := rows.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		keyRow := map[string]interface{}{}
+		values := make([]interface{}, len(groupBy))
+		for i, column := range groupBy {
+			value, ok := row[column.key()]
+			if !ok {
+				return nil, UnknownIdentifierError{column.key()}
+			}
+			keyRow[column.key()] = value
+			values[i] = value
+		}
+
+		key := rowKey(values)
+		g, ok := groups[key]
+		if !ok {
+			if g, err = newGroup(keyRow); err != nil {
+				return nil, err
+			}
+			groups[key] = g
+		}
+
+		for i, aggregateCommon := range aggregateCommons {
+			if err := aggregateCommon.accumulate(g.aggregates[i], row); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	result := make([]map[string]interface{}, len(order))
+	for i, g := range order {
+		for j, aggregateCommon := range aggregateCommons {
+			g.row[aggregateCommon.Key()] = g.aggregates[j].Result()
+		}
+		result[i] = g.row
+	}
+
+	return result, nil
+}
+
+//CollectAggregates returns the aggregates used in the given expressions, without duplicate keys.
+func CollectAggregates(expressions ...Expression) []*AggregateCommon {
+	collected := []*AggregateCommon{}
+	seen := map[string]bool{}
+
+	for _, expression := range expressions {
+		Inspect(expression, func(e Expression) bool {
+			aggregate, ok := e.(*AggregateCommon)
+			if !ok {
+				return true
+			}
+
+			if !seen[aggregate.Key()] {
+				seen[aggregate.Key()] = true
+				collected = append(collected, aggregate)
+			}
+			return false
+		})
+	}
+
+	return collected
+}
+
+//ungroupedReferences returns the identifiers referenced by an expression outside of any aggregate.
+func ungroupedReferences(expression Expression) []string {
+	references := []string{}
+
+	Inspect(expression, func(e Expression) bool {
+		switch id := e.(type) {
+		case *AggregateCommon:
+			return false
+		case *IdCommon:
+			references = append(references, id.Key())
+		}
+		return true
+	})
+
+	return references
+}
+
+//FilterGroups returns the grouped rows satisfying the HAVING condition.
+func FilterGroups(groups []map[string]interface{}, having Expression) ([]map[string]interface{}, error) {
+	filtered := []map[string]interface{}{}
+
+	for _, group := range groups {
+		ok, err := EvaluatePredicate(having, group)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			filtered = append(filtered, group)
+		}
+	}
+
+	return filtered, nil
+}
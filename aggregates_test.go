@@ -0,0 +1,133 @@
+package common
+
+import "testing"
+
+func TestDistinctMergeKeepsValueTypes(t *testing.T) {
+	newSum := func(values ...interface{}) Aggregate {
+		aggregate, err := NewAggregate("SUM", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, value := range values {
+			if err := aggregate.Accumulate(value); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return aggregate
+	}
+
+	merged := newSum()
+	if err := merged.Merge(newSum(2.0, 2.0, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if result := merged.Result(); result != 2.0 {
+		t.Errorf("expected 2.0 (float64), got %v (%T)", result, result)
+	}
+
+	merged = newSum(int64(2))
+	if err := merged.Merge(newSum(2.0, 3.5)); err != nil {
+		t.Fatal(err)
+	}
+
+	if result := merged.Result(); result != 5.5 {
+		t.Errorf("expected 5.5, got %v (%T)", result, result)
+	}
+}
+
+func TestGroupRows(t *testing.T) {
+	x := NewIdCommon("x", "")
+	aggregates := []*AggregateCommon{
+		NewAggregateCommon("count", nil, false),
+		NewAggregateCommon("count", x, false),
+		NewAggregateCommon("count", x, true),
+		NewAggregateCommon("sum", x, false),
+		NewAggregateCommon("avg", x, false),
+		NewAggregateCommon("min", x, false),
+		NewAggregateCommon("max", x, false),
+	}
+
+	rows := NewSliceIterator([]map[string]interface{}{
+		{"d": "a", "x": int64(1)},
+		{"d": "b", "x": int64(5)},
+		{"d": "a", "x": int64(3)},
+		{"d": nil, "x": int64(7)},
+		{"d": "a", "x": nil},
+		{"d": "a", "x": int64(3)},
+		{"d": nil, "x": nil},
+	})
+
+	grouped, err := GroupRows(rows, []GroupBySelect{*NewGroupBySelect("", "d")}, aggregates)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		group  interface{}
+		values []interface{}
+	}{
+		{"a", []interface{}{int64(4), int64(3), int64(2), int64(7), 7.0 / 3, int64(1), int64(3)}},
+		{"b", []interface{}{int64(1), int64(1), int64(1), int64(5), 5.0, int64(5), int64(5)}},
+		{nil, []interface{}{int64(2), int64(1), int64(1), int64(7), 7.0, int64(7), int64(7)}},
+	}
+
+	if len(grouped) != len(expected) {
+		t.Fatalf("expected %d groups, got %d: %v", len(expected), len(grouped), grouped)
+	}
+
+	for i, group := range expected {
+		if grouped[i]["d"] != group.group {
+			t.Errorf("group %d: expected d = %v, got %v", i, group.group, grouped[i]["d"])
+		}
+		for j, aggregate := range aggregates {
+			if value := grouped[i][aggregate.Key()]; value != group.values[j] {
+				t.Errorf("group %v: expected %s = %v (%T), got %v (%T)", group.group, aggregate.Key(), group.values[j], group.values[j], value, value)
+			}
+		}
+	}
+}
+
+func TestGroupRowsWithoutGroupBy(t *testing.T) {
+	count := NewAggregateCommon("COUNT", nil, false)
+	sum := NewAggregateCommon("SUM", NewIdCommon("x", ""), false)
+
+	grouped, err := GroupRows(NewSliceIterator(nil), nil, []*AggregateCommon{count, sum})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(grouped) != 1 || grouped[0][count.Key()] != int64(0) || grouped[0][sum.Key()] != nil {
+		t.Errorf("expected a single group with COUNT(*) = 0 and SUM(x) = NULL, got %v", grouped)
+	}
+
+	rows := NewSliceIterator([]map[string]interface{}{{"x": int64(1)}, {"x": 2.5}})
+	if grouped, err = GroupRows(rows, nil, []*AggregateCommon{sum}); err != nil || grouped[0][sum.Key()] != 3.5 {
+		t.Errorf("expected SUM(x) = 3.5, got %v (%v)", grouped, err)
+	}
+}
+
+func TestGroupRowsErrors(t *testing.T) {
+	rows := func() RowIterator {
+		return NewSliceIterator([]map[string]interface{}{{"x": "a"}})
+	}
+
+	if _, err := GroupRows(rows(), []GroupBySelect{*NewGroupBySelect("t", "x")}, nil); err != (UnknownIdentifierError{"t.x"}) {
+		t.Errorf("expected an UnknownIdentifierError, got %v", err)
+	}
+	if _, err := GroupRows(rows(), nil, []*AggregateCommon{NewAggregateCommon("median", nil, false)}); err != (UnknownFunctionError{"MEDIAN"}) {
+		t.Errorf("expected an UnknownFunctionError, got %v", err)
+	}
+	if _, err := GroupRows(rows(), nil, []*AggregateCommon{NewAggregateCommon("sum", NewIdCommon("x", ""), false)}); err == nil {
+		t.Error("expected an error summing strings")
+	}
+}
+
+func TestCollectAggregates(t *testing.T) {
+	sum := NewAggregateCommon("sum", NewIdCommon("x", ""), false)
+	expression := NewGtCommon(NewAggregateCommon("count", nil, false), NewSumCommon(NewIntCommon(1), NewAggregateCommon("sum", NewIdCommon("x", ""), false)))
+
+	collected := CollectAggregates(expression, sum, nil)
+	if len(collected) != 2 || collected[0].Key() != sum.Key() || collected[1].Key() != NewAggregateCommon("count", nil, false).Key() {
+		t.Errorf("expected SUM(x) and COUNT(*), got %v", collected)
+	}
+}
@@ -0,0 +1,980 @@
+package common
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"time"
+	"unicode/utf8"
+)
+
+//BinaryVersion is the version of the binary encoding written by MarshalBinary. It is the first
+//varint of every encoded command or expression.
+const BinaryVersion = 1
+
+//maxBinaryDepth bounds the nesting of decoded expressions and subqueries.
+const maxBinaryDepth = 10000
+
+//ErrMalformedBinary is returned when binary encoded data is not a valid command or expression.
+var ErrMalformedBinary = errors.New("Malformed binary encoding")
+
+//Node tags of the binary encoding. The zero tag encodes a missing expression. New tags must be
+//appended to keep previously encoded data readable.
+const (
+	nilTag uint64 = iota
+	idTag
+	intTag
+	floatTag
+	boolTag
+	stringTag
+	nullTag
+	trueTag
+	falseTag
+	sumTag
+	subTag
+	multTag
+	divTag
+	eqTag
+	neTag
+	ltTag
+	gtTag
+	lteTag
+	gteTag
+	andTag
+	orTag
+	notTag
+	likeTag
+	betweenTag
+	inTag
+	inSelectTag
+	existsTag
+	scalarSubqueryTag
+	isNullTag
+	isBoolTag
+	isDistinctFromTag
+	caseTag
+	funcCallTag
+	aggregateTag
+)
+
+//Value tags of the binary encoding, used for inserted values and column defaults.
+const (
+	nullValueTag uint64 = iota
+	intValueTag
+	floatValueTag
+	boolValueTag
+	stringValueTag
+	datetimeValueTag
+	expressionValueTag
+)
+
+//Command tags of the binary encoding.
+const (
+	createTag uint64 = iota
+	selectTag
+	compoundSelectTag
+	insertTag
+	updateTag
+	deleteTag
+	dropTag
+	alterTag
+)
+
+//Selector and alter instruction tags of the binary encoding.
+const (
+	starSelectorTag uint64 = iota
+	columnSelectorTag
+)
+
+const (
+	alterDropTag uint64 = iota
+	alterAddTag
+	alterModifyTag
+)
+
+//MarshalBinary encodes the command and its instruction type. The Instruction function is not encoded.
+func (c Command) MarshalBinary() ([]byte, error) {
+	enc := newBinaryEncoder()
+	enc.uvarint(uint64(c.InstructionType))
+	enc.command(c.tableModifier)
+	return enc.buffer, enc.err
+}
+
+//UnmarshalBinary decodes a command encoded by MarshalBinary. The decoded command has no Instruction function.
+func (c *Command) UnmarshalBinary(data []byte) error {
+	dec := newBinaryDecoder(data)
+	instructionType := InstructionType(dec.uvarint())
+	if _, ok := instructionName[instructionType]; !ok {
+		dec.fail(ErrMalformedBinary)
+	}
+	tableModifier := dec.command()
+	if err := dec.finish(); err != nil {
+		return err
+	}
+
+	*c = NewCommand(tableModifier, instructionType, nil)
+	return nil
+}
+
+//MarshalExpressionBinary encodes an expression tree in the binary encoding.
+func MarshalExpressionBinary(expression Expression) ([]byte, error) {
+	enc := newBinaryEncoder()
+	enc.expression(expression)
+	return enc.buffer, enc.err
+}
+
+//UnmarshalExpressionBinary decodes an expression tree encoded by MarshalExpressionBinary.
+func UnmarshalExpressionBinary(data []byte) (Expression, error) {
+	dec := newBinaryDecoder(data)
+	expression := dec.expression()
+	if err := dec.finish(); err != nil {
+		return nil, err
+	}
+	return expression, nil
+}
+
+//binaryEncoder appends the binary encoding of commands and expressions to a buffer, keeping the first error found.
+type binaryEncoder struct {
+	buffer []byte
+	err    error
+}
+
+func newBinaryEncoder() *binaryEncoder {
+	enc := &binaryEncoder{}
+	enc.uvarint(BinaryVersion)
+	return enc
+}
+
+func (enc *binaryEncoder) fail(err error) {
+	if enc.err == nil {
+		enc.err = err
+	}
+}
+
+func (enc *binaryEncoder) uvarint(value uint64) {
+	enc.buffer = binary.AppendUvarint(enc.buffer, value)
+}
+
+func (enc *binaryEncoder) varint(value int64) {
+	enc.buffer = binary.AppendVarint(enc.buffer, value)
+}
+
+func (enc *binaryEncoder) float(value float64) {
+	enc.buffer = binary.LittleEndian.AppendUint64(enc.buffer, math.Float64bits(value))
+}
+
+func (enc *binaryEncoder) boolean(value bool) {
+	if value {
+		enc.buffer = append(enc.buffer, 1)
+	} else {
+		enc.buffer = append(enc.buffer, 0)
+	}
+}
+
+func (enc *binaryEncoder) bytes(value []byte) {
+	enc.uvarint(uint64(len(value)))
+	enc.buffer = append(enc.buffer, value...)
+}
+
+func (enc *binaryEncoder) string(value string) {
+	enc.uvarint(uint64(len(value)))
+	enc.buffer = append(enc.buffer, value...)
+}
+
+func (enc *binaryEncoder) strings(values []string) {
+	enc.uvarint(uint64(len(values)))
+	for _, value := range values {
+		enc.string(value)
+	}
+}
+
+func (enc *binaryEncoder) binary(tag uint64, left Expression, right Expression) {
+	enc.uvarint(tag)
+	enc.expression(left)
+	enc.expression(right)
+}
+
+func (enc *binaryEncoder) expressions(expressions []Expression) {
+	enc.uvarint(uint64(len(expressions)))
+	for _, expression := range expressions {
+		enc.expression(expression)
+	}
+}
+
+func (enc *binaryEncoder) expression(expression Expression) {
+	if enc.err != nil {
+		return
+	}
+
+	switch e := expression.(type) {
+	case nil:
+		enc.uvarint(nilTag)
+	case *IdCommon:
+		enc.uvarint(idTag)
+		enc.string(e.name)
+		enc.string(e.alias)
+	case *IntCommon:
+		enc.uvarint(intTag)
+		enc.varint(e.value)
+	case *FloatCommon:
+		enc.uvarint(floatTag)
+		enc.float(e.value)
+	case *BoolCommon:
+		enc.uvarint(boolTag)
+		enc.boolean(e.value)
+	case *StringCommon:
+		enc.uvarint(stringTag)
+		enc.string(e.value)
+	case *NullCommon:
+		enc.uvarint(nullTag)
+	case *TrueCommon:
+		enc.uvarint(trueTag)
+	case *FalseCommon:
+		enc.uvarint(falseTag)
+	case *SumCommon:
+		enc.binary(sumTag, e.leftValue, e.rightValue)
+	case *SubCommon:
+		enc.binary(subTag, e.leftValue, e.rightValue)
+	case *MultCommon:
+		enc.binary(multTag, e.leftValue, e.rightValue)
+	case *DivCommon:
+		enc.binary(divTag, e.leftValue, e.rightValue)
+	case *EqCommon:
+		enc.binary(eqTag, e.leftValue, e.rightValue)
+	case *NeCommon:
+		enc.binary(neTag, e.leftValue, e.rightValue)
+	case *LtCommon:
+		enc.binary(ltTag, e.leftValue, e.rightValue)
+	case *GtCommon:
+		enc.binary(gtTag, e.leftValue, e.rightValue)
+	case *LteCommon:
+		enc.binary(lteTag, e.leftValue, e.rightValue)
+	case *GteCommon:
+		enc.binary(gteTag, e.leftValue, e.rightValue)
+	case *AndCommon:
+		enc.binary(andTag, e.leftValue, e.rightValue)
+	case *OrCommon:
+		enc.binary(orTag, e.leftValue, e.rightValue)
+	case *NotCommon:
+		enc.uvarint(notTag)
+		enc.expression(e.not)
+	case *LikeCommon:
+		enc.binary(likeTag, e.leftValue, e.rightValue)
+		enc.varint(int64(e.escape))
+		enc.boolean(e.caseInsensitive)
+	case *BetweenCommon:
+		enc.uvarint(betweenTag)
+		enc.expression(e.value)
+		enc.expression(e.lower)
+		enc.expression(e.upper)
+		enc.boolean(e.not)
+		enc.boolean(e.symmetric)
+	case *InCommon:
+		enc.uvarint(inTag)
+		enc.expression(e.value)
+		enc.expressions(e.list)
+		enc.boolean(e.not)
+	case *InSelectCommon:
+		enc.uvarint(inSelectTag)
+		enc.expression(e.value)
+		enc.query(e.query)
+		enc.boolean(e.not)
+	case *ExistsCommon:
+		enc.uvarint(existsTag)
+		enc.query(e.query)
+		enc.boolean(e.not)
+	case *ScalarSubqueryCommon:
+		enc.uvarint(scalarSubqueryTag)
+		enc.query(e.query)
+	case *IsNullCommon:
+		enc.uvarint(isNullTag)
+		enc.expression(e.value)
+		enc.boolean(e.not)
+	case *IsBoolCommon:
+		enc.uvarint(isBoolTag)
+		enc.expression(e.value)
+		enc.boolean(e.truth)
+		enc.boolean(e.not)
+	case *IsDistinctFromCommon:
+		enc.binary(isDistinctFromTag, e.leftValue, e.rightValue)
+		enc.boolean(e.not)
+	case *CaseCommon:
+		enc.uvarint(caseTag)
+		enc.expression(e.operand)
+		enc.uvarint(uint64(len(e.whens)))
+		for _, when := range e.whens {
+			enc.expression(when.condition)
+			enc.expression(when.result)
+		}
+		enc.expression(e.elseValue)
+	case *FuncCallCommon:
+		enc.uvarint(funcCallTag)
+		enc.string(e.name)
+		enc.expressions(e.args)
+	case *AggregateCommon:
+		enc.uvarint(aggregateTag)
+		enc.string(e.name)
+		enc.expression(e.argument)
+		enc.boolean(e.distinct)
+	default:
+		enc.fail(UnsupportedValueError{expression})
+	}
+}
+
+func (enc *binaryEncoder) value(value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		enc.uvarint(nullValueTag)
+	case int64:
+		enc.uvarint(intValueTag)
+		enc.varint(v)
+	case int:
+		enc.uvarint(intValueTag)
+		enc.varint(int64(v))
+	case float64:
+		enc.uvarint(floatValueTag)
+		enc.float(v)
+	case bool:
+		enc.uvarint(boolValueTag)
+		enc.boolean(v)
+	case string:
+		enc.uvarint(stringValueTag)
+		enc.string(v)
+	case time.Time:
+		data, err := v.MarshalBinary()
+		if err != nil {
+			enc.fail(err)
+		}
+		enc.uvarint(datetimeValueTag)
+		enc.bytes(data)
+	case Expression:
+		enc.uvarint(expressionValueTag)
+		enc.expression(v)
+	default:
+		enc.fail(UnsupportedValueError{value})
+	}
+}
+
+func (enc *binaryEncoder) definer(definer TableColumnDefiner) {
+	columnType := ColumnType(definer)
+	if columnType == UnknownType {
+		enc.fail(UnsupportedValueError{definer})
+		return
+	}
+
+	enc.uvarint(uint64(columnType))
+	enc.string(definer.ColumnName())
+	enc.value(definer.DefaultValue())
+	enc.boolean(definer.Nullable())
+	enc.boolean(definer.Autoincrementable())
+	enc.boolean(definer.PrimaryKey())
+	enc.boolean(definer.ForeignKey())
+
+	switch c := definer.(type) {
+	case CharTableColumn:
+		enc.uvarint(uint64(c.size))
+	case *CharTableColumn:
+		enc.uvarint(uint64(c.size))
+	}
+}
+
+func (enc *binaryEncoder) selector(selector interface{}) {
+	switch s := selector.(type) {
+	case TableColumnStarSelector, *TableColumnStarSelector:
+		enc.uvarint(starSelectorTag)
+	case TableColumnSelector:
+		enc.selector(&s)
+	case *TableColumnSelector:
+		enc.uvarint(columnSelectorTag)
+		enc.boolean(s.isStar)
+		enc.string(s.prefix)
+		enc.string(s.columnName)
+		enc.string(s.alias)
+		enc.expression(s.function)
+	default:
+		enc.fail(UnsupportedValueError{selector})
+	}
+}
+
+func (enc *binaryEncoder) query(query *SelectTableCommand) {
+	if query == nil {
+		enc.fail(UnsupportedValueError{query})
+		return
+	}
+
+	enc.string(query.tableName)
+	enc.string(query.mainAlias)
+
+	enc.uvarint(uint64(len(query.tableColumnSelectors)))
+	for _, selector := range query.tableColumnSelectors {
+		enc.selector(selector)
+	}
+
+	enc.uvarint(uint64(len(query.joinList)))
+	for _, join := range query.joinList {
+		enc.uvarint(uint64(join.kind))
+		enc.string(join.targetTable)
+		enc.string(join.targetAlias)
+		enc.expression(join.filterCriteria)
+		enc.strings(join.usingColumns)
+	}
+
+	enc.expression(query.whereExpression)
+
+	enc.uvarint(uint64(len(query.groupBy)))
+	for _, column := range query.groupBy {
+		enc.string(column.table)
+		enc.string(column.column)
+	}
+
+	enc.expression(query.havingExpression)
+
+	enc.uvarint(uint64(len(query.orderBy)))
+	for _, order := range query.orderBy {
+		enc.expression(order.expression)
+		enc.boolean(order.descending)
+		enc.uvarint(uint64(order.nulls))
+	}
+
+	enc.boolean(query.hasLimit)
+	enc.varint(query.limit)
+	enc.varint(query.offset)
+	enc.boolean(query.distinct)
+}
+
+func (enc *binaryEncoder) command(tableModifier tableModifier) {
+	switch c := tableModifier.(type) {
+	case *CreateTableCommand:
+		enc.uvarint(createTag)
+		enc.string(c.tableName)
+		enc.uvarint(uint64(len(c.tableColumnDefiners)))
+		for _, definer := range c.tableColumnDefiners {
+			enc.definer(definer)
+		}
+	case *SelectTableCommand:
+		enc.uvarint(selectTag)
+		enc.query(c)
+	case *CompoundSelectCommand:
+		enc.uvarint(compoundSelectTag)
+		enc.query(c.left)
+		enc.query(c.right)
+		enc.uvarint(uint64(c.operator))
+		enc.boolean(c.all)
+	case *InsertCommand:
+		enc.uvarint(insertTag)
+		enc.string(c.tableName)
+
+		columns := make([]string, 0, len(c.values))
+		for column := range c.values {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+
+		enc.uvarint(uint64(len(columns)))
+		for _, column := range columns {
+			enc.string(column)
+			enc.value(c.values[column])
+		}
+	case *UpdateTableCommand:
+		enc.uvarint(updateTag)
+		enc.string(c.tableName)
+		enc.uvarint(uint64(len(c.assignments)))
+		for _, assignment := range c.assignments {
+			enc.string(assignment.value)
+			enc.expression(assignment.expression)
+		}
+		enc.expression(c.where)
+	case *DeleteCommand:
+		enc.uvarint(deleteTag)
+		enc.string(c.tableName)
+		enc.string(c.alias)
+		enc.expression(c.where)
+	case *DropCommand:
+		enc.uvarint(dropTag)
+		enc.string(c.tableName)
+	case *AlterCommand:
+		enc.uvarint(alterTag)
+		enc.string(c.table)
+		switch i := c.instruction.(type) {
+		case *AlterDropInst:
+			enc.uvarint(alterDropTag)
+			enc.string(i.table)
+		case *AlterAddInst:
+			enc.uvarint(alterAddTag)
+			enc.definer(i.tableColumnDefiners)
+		case *AlterModifyInst:
+			enc.uvarint(alterModifyTag)
+			enc.definer(i.tableColumnDefiners)
+		default:
+			enc.fail(UnsupportedValueError{c.instruction})
+		}
+	default:
+		enc.fail(UnsupportedValueError{tableModifier})
+	}
+}
+
+//binaryDecoder reads commands and expressions from binary encoded data. Once an error is found every
+//read returns a zero value, so the decoder never panics on malformed input.
+type binaryDecoder struct {
+	data  []byte
+	depth int
+	err   error
+}
+
+func newBinaryDecoder(data []byte) *binaryDecoder {
+	dec := &binaryDecoder{data: data}
+	if version := dec.uvarint(); dec.err == nil && version != BinaryVersion {
+		dec.fail(BinaryVersionError{version})
+	}
+	return dec
+}
+
+func (dec *binaryDecoder) fail(err error) {
+	if dec.err == nil {
+		dec.err = err
+	}
+}
+
+//finish returns the first error found, or ErrMalformedBinary if there is data left after the decoded value.
+func (dec *binaryDecoder) finish() error {
+	if dec.err == nil && len(dec.data) > 0 {
+		dec.fail(ErrMalformedBinary)
+	}
+	return dec.err
+}
+
+func (dec *binaryDecoder) uvarint() uint64 {
+	if dec.err != nil {
+		return 0
+	}
+
+	value, n := binary.Uvarint(dec.data)
+	if n <= 0 {
+		dec.fail(dec.varintError(n))
+		return 0
+	}
+	dec.data = dec.data[n:]
+	return value
+}
+
+func (dec *binaryDecoder) varint() int64 {
+	if dec.err != nil {
+		return 0
+	}
+
+	value, n := binary.Varint(dec.data)
+	if n <= 0 {
+		dec.fail(dec.varintError(n))
+		return 0
+	}
+	dec.data = dec.data[n:]
+	return value
+}
+
+//varintError returns the error of a failed varint read, where n == 0 means that the data ended.
+func (dec *binaryDecoder) varintError(n int) error {
+	if n == 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return ErrMalformedBinary
+}
+
+func (dec *binaryDecoder) next(size int) []byte {
+	if dec.err != nil {
+		return nil
+	}
+
+	if size > len(dec.data) {
+		dec.fail(io.ErrUnexpectedEOF)
+		return nil
+	}
+	value := dec.data[:size]
+	dec.data = dec.data[size:]
+	return value
+}
+
+//length reads the number of elements of a list. Every element takes at least one byte, which bounds
+//the length by the remaining data before anything is allocated.
+func (dec *binaryDecoder) length() int {
+	length := dec.uvarint()
+	if length > uint64(len(dec.data)) {
+		dec.fail(io.ErrUnexpectedEOF)
+		return 0
+	}
+	return int(length)
+}
+
+func (dec *binaryDecoder) float() float64 {
+	data := dec.next(8)
+	if data == nil {
+		return 0
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(data))
+}
+
+func (dec *binaryDecoder) boolean() bool {
+	data := dec.next(1)
+	if data == nil {
+		return false
+	}
+
+	if data[0] > 1 {
+		dec.fail(ErrMalformedBinary)
+	}
+	return data[0] == 1
+}
+
+func (dec *binaryDecoder) bytes() []byte {
+	return dec.next(dec.length())
+}
+
+func (dec *binaryDecoder) string() string {
+	return string(dec.bytes())
+}
+
+func (dec *binaryDecoder) strings() []string {
+	length := dec.length()
+	if length == 0 {
+		return nil
+	}
+
+	values := make([]string, length)
+	for i := range values {
+		values[i] = dec.string()
+	}
+	return values
+}
+
+//enter increments the nesting depth and returns false when it is too deep to keep decoding.
+func (dec *binaryDecoder) enter() bool {
+	dec.depth++
+	if dec.depth > maxBinaryDepth {
+		dec.fail(ErrMalformedBinary)
+	}
+	return dec.err == nil
+}
+
+func (dec *binaryDecoder) leave() {
+	dec.depth--
+}
+
+//operand decodes an expression that cannot be missing.
+func (dec *binaryDecoder) operand() Expression {
+	expression := dec.expression()
+	if expression == nil {
+		dec.fail(ErrMalformedBinary)
+	}
+	return expression
+}
+
+//operands decodes the operands of a binary node, returned in the order expected by the node constructors.
+func (dec *binaryDecoder) operands() (value Expression, expression Expression) {
+	expression = dec.operand()
+	value = dec.operand()
+	return value, expression
+}
+
+func (dec *binaryDecoder) expressions() []Expression {
+	length := dec.length()
+	if length == 0 {
+		return nil
+	}
+
+	expressions := make([]Expression, length)
+	for i := range expressions {
+		expressions[i] = dec.operand()
+	}
+	return expressions
+}
+
+func (dec *binaryDecoder) expression() Expression {
+	if !dec.enter() {
+		return nil
+	}
+	defer dec.leave()
+
+	expression := dec.node(dec.uvarint())
+	if dec.err != nil {
+		return nil
+	}
+	return expression
+}
+
+func (dec *binaryDecoder) node(tag uint64) Expression {
+	if dec.err != nil {
+		return nil
+	}
+
+	switch tag {
+	case nilTag:
+		return nil
+	case idTag:
+		return NewIdCommon(dec.string(), dec.string())
+	case intTag:
+		return NewIntCommon(dec.varint())
+	case floatTag:
+		return NewFloatCommon(dec.float())
+	case boolTag:
+		return NewBoolCommon(dec.boolean())
+	case stringTag:
+		return NewStringCommon(dec.string())
+	case nullTag:
+		return NewNullCommon()
+	case trueTag:
+		return NewTrueCommon()
+	case falseTag:
+		return NewFalseCommon()
+	case sumTag:
+		return NewSumCommon(dec.operands())
+	case subTag:
+		return NewSubCommon(dec.operands())
+	case multTag:
+		return NewMultCommon(dec.operands())
+	case divTag:
+		return NewDivCommon(dec.operands())
+	case eqTag:
+		return NewEqCommon(dec.operands())
+	case neTag:
+		return NewNeCommon(dec.operands())
+	case ltTag:
+		return NewLtCommon(dec.operands())
+	case gtTag:
+		return NewGtCommon(dec.operands())
+	case lteTag:
+		return NewLteCommon(dec.operands())
+	case gteTag:
+		return NewGteCommon(dec.operands())
+	case andTag:
+		return NewAndCommon(dec.operands())
+	case orTag:
+		return NewOrCommon(dec.operands())
+	case notTag:
+		return NewNotCommon(dec.operand())
+	case likeTag:
+		value, expression := dec.operands()
+		escape := dec.varint()
+		if escape != 0 && (escape < 0 || escape > utf8.MaxRune || !utf8.ValidRune(rune(escape))) {
+			dec.fail(ErrMalformedBinary)
+		}
+		caseInsensitive := dec.boolean()
+		if dec.err != nil {
+			return nil
+		}
+		return NewLikeEscapeCommon(value, expression, rune(escape), caseInsensitive)
+	case betweenTag:
+		return NewBetweenCommon(dec.operand(), dec.operand(), dec.operand(), dec.boolean(), dec.boolean())
+	case inTag:
+		return NewInCommon(dec.operand(), dec.expressions(), dec.boolean())
+	case inSelectTag:
+		return NewInSelectCommon(dec.operand(), dec.query(), dec.boolean())
+	case existsTag:
+		return NewExistsCommon(dec.query(), dec.boolean())
+	case scalarSubqueryTag:
+		return NewScalarSubqueryCommon(dec.query())
+	case isNullTag:
+		return NewIsNullCommon(dec.operand(), dec.boolean())
+	case isBoolTag:
+		return NewIsBoolCommon(dec.operand(), dec.boolean(), dec.boolean())
+	case isDistinctFromTag:
+		value, expression := dec.operands()
+		return NewIsDistinctFromCommon(value, expression, dec.boolean())
+	case caseTag:
+		operand := dec.expression()
+		whens := make([]*WhenCommon, dec.length())
+		for i := range whens {
+			whens[i] = NewWhenCommon(dec.operand(), dec.operand())
+		}
+		elseValue := dec.expression()
+		if dec.err != nil || len(whens) == 0 {
+			dec.fail(ErrMalformedBinary)
+			return nil
+		}
+		return NewCaseCommon(operand, whens, elseValue)
+	case funcCallTag:
+		return NewFuncCallCommon(dec.string(), dec.expressions())
+	case aggregateTag:
+		return NewAggregateCommon(dec.string(), dec.expression(), dec.boolean())
+	}
+
+	dec.fail(UnknownNodeTagError{tag})
+	return nil
+}
+
+func (dec *binaryDecoder) value() interface{} {
+	switch tag := dec.uvarint(); tag {
+	case nullValueTag:
+		return nil
+	case intValueTag:
+		return dec.varint()
+	case floatValueTag:
+		return dec.float()
+	case boolValueTag:
+		return dec.boolean()
+	case stringValueTag:
+		return dec.string()
+	case datetimeValueTag:
+		var value time.Time
+		if data := dec.bytes(); dec.err == nil {
+			if err := value.UnmarshalBinary(data); err != nil {
+				dec.fail(ErrMalformedBinary)
+			}
+		}
+		return value
+	case expressionValueTag:
+		return dec.operand()
+	default:
+		dec.fail(UnknownNodeTagError{tag})
+	}
+	return nil
+}
+
+func (dec *binaryDecoder) definer() TableColumnDefiner {
+	columnType := Type(dec.uvarint())
+	base := baseTableColumn{dec.string(), dec.value(), dec.boolean(), dec.boolean(), dec.boolean(), dec.boolean()}
+
+	switch columnType {
+	case IntegerType:
+		return IntegerTableColumn{base}
+	case FloatType:
+		return FloatTableColumn{base}
+	case BooleanType:
+		return BooleanTableColumn{base}
+	case DatetimeType:
+		return DatetimeTableColumn{base}
+	case CharType:
+		size := dec.uvarint()
+		if size > math.MaxUint16 {
+			dec.fail(ErrMalformedBinary)
+		}
+		return CharTableColumn{base, uint16(size)}
+	}
+
+	dec.fail(UnknownNodeTagError{uint64(columnType)})
+	return nil
+}
+
+func (dec *binaryDecoder) selector() interface{} {
+	switch tag := dec.uvarint(); tag {
+	case starSelectorTag:
+		return NewTableColumnStarSelector()
+	case columnSelectorTag:
+		isStar, prefix, columnName, alias := dec.boolean(), dec.string(), dec.string(), dec.string()
+		return NewTableColumnSelector(isStar, prefix, columnName, alias, dec.expression())
+	default:
+		dec.fail(UnknownNodeTagError{tag})
+	}
+	return nil
+}
+
+func (dec *binaryDecoder) query() *SelectTableCommand {
+	if !dec.enter() {
+		return nil
+	}
+	defer dec.leave()
+
+	tableName, mainAlias := dec.string(), dec.string()
+
+	selectors := make(TableColumnSelectors, dec.length())
+	for i := range selectors {
+		selectors[i] = dec.selector()
+	}
+
+	var joins []JoinSelect
+	for i, length := 0, dec.length(); i < length; i++ {
+		kind := JoinKind(dec.uvarint())
+		if _, ok := joinKindName[kind]; !ok {
+			dec.fail(ErrMalformedBinary)
+		}
+		joins = append(joins, *NewJoinSelectWithKind(kind, dec.string(), dec.string(), dec.expression(), dec.strings()))
+	}
+
+	where := dec.expression()
+
+	var groupBy []GroupBySelect
+	for i, length := 0, dec.length(); i < length; i++ {
+		groupBy = append(groupBy, *NewGroupBySelect(dec.string(), dec.string()))
+	}
+
+	having := dec.expression()
+
+	var orderBy []OrderBySelect
+	for i, length := 0, dec.length(); i < length; i++ {
+		expression, descending, nulls := dec.operand(), dec.boolean(), NullsOrder(dec.uvarint())
+		if _, ok := nullsOrderName[nulls]; !ok {
+			dec.fail(ErrMalformedBinary)
+		}
+		orderBy = append(orderBy, *NewOrderBySelect(expression, descending, nulls))
+	}
+
+	hasLimit, limit, offset, distinct := dec.boolean(), dec.varint(), dec.varint(), dec.boolean()
+	if dec.err != nil {
+		return nil
+	}
+
+	return NewSelectTableCommand(tableName, mainAlias, selectors, joins, where, groupBy, having, orderBy, limit, hasLimit, offset, distinct)
+}
+
+func (dec *binaryDecoder) alterInstruction() interface{} {
+	switch tag := dec.uvarint(); tag {
+	case alterDropTag:
+		return NewAlterDropInst(dec.string())
+	case alterAddTag:
+		return NewAlterAddInst(dec.definer())
+	case alterModifyTag:
+		return NewAlterModifyInst(dec.definer())
+	default:
+		dec.fail(UnknownNodeTagError{tag})
+	}
+	return nil
+}
+
+func (dec *binaryDecoder) command() tableModifier {
+	switch tag := dec.uvarint(); tag {
+	case createTag:
+		tableName := dec.string()
+		definers := make(TableColumnDefiners, dec.length())
+		for i := range definers {
+			definers[i] = dec.definer()
+		}
+		return NewCreateTableCommand(tableName, definers)
+	case selectTag:
+		if query := dec.query(); query != nil {
+			return query
+		}
+	case compoundSelectTag:
+		left, right := dec.query(), dec.query()
+		operator, all := SetOperator(dec.uvarint()), dec.boolean()
+		if _, ok := setOperatorName[operator]; !ok {
+			dec.fail(ErrMalformedBinary)
+		}
+		if dec.err == nil {
+			return NewCompoundSelectCommand(left, right, operator, all)
+		}
+	case insertTag:
+		tableName := dec.string()
+		values := map[string]interface{}{}
+		for i, length := 0, dec.length(); i < length; i++ {
+			column := dec.string()
+			values[column] = dec.value()
+		}
+		return NewInsertCommand(tableName, values)
+	case updateTag:
+		tableName := dec.string()
+		assignments := make([]*AssignmentCommon, dec.length())
+		for i := range assignments {
+			assignments[i] = NewAssignmentCommon(dec.string(), dec.operand())
+		}
+		return NewUpdateTableCommand(tableName, assignments, dec.expression())
+	case deleteTag:
+		return NewDeleteTableCommand(dec.string(), dec.string(), dec.expression())
+	case dropTag:
+		return NewDropCommand(dec.string())
+	case alterTag:
+		return NewAlterCommand(dec.string(), dec.alterInstruction())
+	default:
+		dec.fail(UnknownNodeTagError{tag})
+	}
+	return nil
+}
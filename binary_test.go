@@ -0,0 +1,141 @@
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExpressionBinaryRoundTrip(t *testing.T) {
+	for _, expression := range sampleExpressions() {
+		data, err := MarshalExpressionBinary(expression)
+		if err != nil {
+			t.Fatalf("%v: %v", expression, err)
+		}
+
+		decoded, err := UnmarshalExpressionBinary(data)
+		if err != nil {
+			t.Fatalf("%v: %v", expression, err)
+		}
+
+		if !reflect.DeepEqual(decoded, expression) {
+			t.Errorf("%v decoded as %v", expression, decoded)
+		}
+	}
+}
+
+func TestCommandBinaryRoundTrip(t *testing.T) {
+	for _, command := range sampleCommands() {
+		data, err := command.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%v: %v", command, err)
+		}
+
+		var decoded Command
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatalf("%v: %v", command, err)
+		}
+
+		if decoded.InstructionType != command.InstructionType || decoded.String() != command.String() {
+			t.Errorf("%v decoded as %v", command, decoded)
+		}
+	}
+}
+
+func TestBinaryLiteralKinds(t *testing.T) {
+	for _, command := range sampleCommands() {
+		insert, ok := command.tableModifier.(*InsertCommand)
+		if !ok {
+			continue
+		}
+
+		data, err := command.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var decoded Command
+		if err := decoded.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+
+		values := decoded.tableModifier.(*InsertCommand).Values()
+		for column, expected := range insert.Values() {
+			value := values[column]
+
+			switch e := expected.(type) {
+			case time.Time:
+				if v, ok := value.(time.Time); !ok || !v.Equal(e) {
+					t.Errorf("%s: expected %v, got %v (%T)", column, e, value, value)
+				}
+			case Expression:
+				if !reflect.DeepEqual(value, e) {
+					t.Errorf("%s: expected %v, got %v", column, e, value)
+				}
+			default:
+				if value != expected {
+					t.Errorf("%s: expected %v (%T), got %v (%T)", column, expected, expected, value, value)
+				}
+			}
+		}
+	}
+}
+
+func TestUnmarshalBinaryRejectsTruncatedData(t *testing.T) {
+	for _, command := range sampleCommands() {
+		data, err := command.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < len(data); i++ {
+			var decoded Command
+			if err := decoded.UnmarshalBinary(data[:i]); err == nil {
+				t.Errorf("%v: decoded %d of %d bytes", command, i, len(data))
+			}
+		}
+
+		var decoded Command
+		if err := decoded.UnmarshalBinary(append(data, 0)); err == nil {
+			t.Errorf("%v: decoded trailing data", command)
+		}
+	}
+}
+
+func FuzzUnmarshalBinary(f *testing.F) {
+	for _, command := range sampleCommands() {
+		data, err := command.MarshalBinary()
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	for _, expression := range sampleExpressions() {
+		data, err := MarshalExpressionBinary(expression)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var command Command
+		if err := command.UnmarshalBinary(data); err == nil {
+			if _, err := command.MarshalBinary(); err != nil {
+				t.Errorf("decoded command cannot be encoded: %v", err)
+			}
+		}
+
+		if expression, err := UnmarshalExpressionBinary(data); err == nil {
+			if _, err := MarshalExpressionBinary(expression); err != nil {
+				t.Errorf("decoded expression cannot be encoded: %v", err)
+			}
+			if stringer, ok := expression.(fmt.Stringer); ok {
+				_ = stringer.String()
+			}
+		} else if expression != nil {
+			t.Errorf("malformed input decoded as %v with error %v", expression, err)
+		}
+	})
+}
@@ -2,6 +2,7 @@ package common
 
 import (
 	"fmt"
+	"reflect"
 )
 
 type tableModifier interface {
@@ -140,11 +141,11 @@ type TableColumnSelector struct {
 	prefix     string
 	columnName string
 	alias      string
-	function   interface{}
+	function   Expression
 }
 
 //NewTableColumnSelector creates an instance of a TableColumnSelector.
-func NewTableColumnSelector(isStar bool, prefix string, columnName string, alias string, function interface{}) *TableColumnSelector {
+func NewTableColumnSelector(isStar bool, prefix string, columnName string, alias string, function Expression) *TableColumnSelector {
 	return &TableColumnSelector{isStar, prefix, columnName, alias, function}
 }
 
@@ -170,6 +171,11 @@ func (s TableColumnSelector) Alias() (string, bool) {
 	return s.alias, len(s.alias) > 0
 }
 
+//Function returns the function applied to the selected column, such as a *FuncCallCommon, or nil.
+func (s TableColumnSelector) Function() Expression {
+	return s.function
+}
+
 //TableColumnStarSelector represents a star selector in a select query.
 type TableColumnStarSelector struct {
 }
@@ -179,32 +185,165 @@ func NewTableColumnStarSelector() *TableColumnStarSelector {
 	return &TableColumnStarSelector{}
 }
 
+//GroupBySelect represents a column in the GROUP BY clause of a select query.
 type GroupBySelect struct {
 	table  string
 	column string
 }
 
-//NewTableColumnSelector creates an instance of a TableColumnSelector.
+//NewGroupBySelect creates an instance of a GroupBySelect.
 func NewGroupBySelect(table string, column string) *GroupBySelect {
 	return &GroupBySelect{table, column}
 }
 
+//Table returns the table prefix of the grouped column and returns true if it isn't empty.
+func (g GroupBySelect) Table() (string, bool) {
+	return g.table, len(g.table) > 0
+}
+
+//Column returns the name of the grouped column.
+func (g GroupBySelect) Column() string {
+	return g.column
+}
+
+func (g GroupBySelect) key() string {
+	if g.table == "" {
+		return g.column
+	}
+	return fmt.Sprintf("%s.%s", g.table, g.column)
+}
+
+//JoinKind is used to determine which rows of a join are kept when they have no match.
+type JoinKind int
+
+//Join kind constants.
+const (
+	InnerJoin JoinKind = iota
+	LeftJoin
+	RightJoin
+	FullJoin
+	CrossJoin
+)
+
+var joinKindName = map[JoinKind]string{
+	InnerJoin: "INNER JOIN",
+	LeftJoin:  "LEFT JOIN",
+	RightJoin: "RIGHT JOIN",
+	FullJoin:  "FULL OUTER JOIN",
+	CrossJoin: "CROSS JOIN",
+}
+
+func (k JoinKind) String() string {
+	return joinKindName[k]
+}
+
+//JoinSelect represents a joined table in a select query.
 type JoinSelect struct {
+	kind           JoinKind
 	targetTable    string
 	targetAlias    string
 	filterCriteria Expression
+	usingColumns   []string
 }
 
 func (j JoinSelect) TargetTable() string {
 	return j.targetTable
 }
 
+//TargetAlias returns the alias of the joined table and returns true if it isn't empty.
+func (j JoinSelect) TargetAlias() (string, bool) {
+	return j.targetAlias, len(j.targetAlias) > 0
+}
+
 func (j JoinSelect) FilterCriteria() Expression {
 	return j.filterCriteria
 }
 
+func (j JoinSelect) Kind() JoinKind {
+	return j.kind
+}
+
+//UsingColumns returns the columns of the USING clause.
+func (j JoinSelect) UsingColumns() []string {
+	return j.usingColumns
+}
+
+//PreservesLeft returns true if left rows without a match are kept and padded with NULL values.
+func (j JoinSelect) PreservesLeft() bool {
+	return j.kind == LeftJoin || j.kind == FullJoin
+}
+
+//PreservesRight returns true if joined rows without a match are kept and padded with NULL values.
+func (j JoinSelect) PreservesRight() bool {
+	return j.kind == RightJoin || j.kind == FullJoin
+}
+
+//Qualifier returns the prefix of the joined table columns, which is its alias or its name when there is no alias.
+func (j JoinSelect) Qualifier() string {
+	if j.targetAlias != "" {
+		return j.targetAlias
+	}
+	return j.targetTable
+}
+
+//Condition returns the join condition, combining the USING columns, compared against the columns
+//prefixed by leftQualifier, with the ON filter. An empty leftQualifier compares the unqualified
+//columns, such as the USING columns of a previous join. It returns nil for a CROSS JOIN.
+func (j JoinSelect) Condition(leftQualifier string) Expression {
+	if j.kind == CrossJoin {
+		return nil
+	}
+
+	condition := j.filterCriteria
+	for _, column := range j.usingColumns {
+		left := NewIdCommon(leftQualifier, column)
+		if leftQualifier == "" {
+			left = NewIdCommon(column, "")
+		}
+
+		equal := NewEqCommon(NewIdCommon(j.Qualifier(), column), left)
+		if condition == nil {
+			condition = equal
+		} else {
+			condition = NewAndCommon(equal, condition)
+		}
+	}
+
+	return condition
+}
+
+//NullRow returns the symbols of a joined row padded with NULL values.
+func (j JoinSelect) NullRow(columns []string) map[string]interface{} {
+	return nullRow(j.Qualifier(), columns)
+}
+
+func nullRow(qualifier string, columns []string) map[string]interface{} {
+	row := map[string]interface{}{}
+	for _, column := range columns {
+		row[qualify(qualifier, column)] = nil
+	}
+	return row
+}
+
+func qualify(qualifier string, column string) string {
+	if qualifier == "" {
+		return column
+	}
+	return fmt.Sprintf("%s.%s", qualifier, column)
+}
+
+//NewJoinSelect creates an instance of an INNER JoinSelect.
 func NewJoinSelect(targetTable string, targetAlias string, filterCriteria Expression) *JoinSelect {
-	return &JoinSelect{targetTable, targetAlias, filterCriteria}
+	return NewJoinSelectWithKind(InnerJoin, targetTable, targetAlias, filterCriteria, nil)
+}
+
+//NewJoinSelectWithKind creates an instance of JoinSelect of the given kind. A CROSS JOIN has no condition,
+//so its filterCriteria and usingColumns are dropped.
+func NewJoinSelectWithKind(kind JoinKind, targetTable string, targetAlias string, filterCriteria Expression, usingColumns []string) *JoinSelect {
+	if kind == CrossJoin {
+		filterCriteria, usingColumns = nil, nil
+	}
+	return &JoinSelect{kind, targetTable, targetAlias, filterCriteria, usingColumns}
 }
 
 //SelectTableCommand represents a select from table query.
@@ -215,11 +354,18 @@ type SelectTableCommand struct {
 	joinList             []JoinSelect
 	whereExpression      Expression
 	groupBy              []GroupBySelect
+	havingExpression     Expression
+	orderBy              []OrderBySelect
+	limit                int64
+	hasLimit             bool
+	offset               int64
+	distinct             bool
 }
 
-//NewSelectTableCommand returns an instance of SelectTableCommand.
-func NewSelectTableCommand(tableName string, mainAlias string, tableColumnSelectors TableColumnSelectors, joinList []JoinSelect, whereExpression Expression, groupBy []GroupBySelect) *SelectTableCommand {
-	return &SelectTableCommand{tableName, mainAlias, tableColumnSelectors, joinList, whereExpression, groupBy}
+//NewSelectTableCommand returns an instance of SelectTableCommand. The limit is only used when hasLimit is set,
+//as by a LIMIT clause.
+func NewSelectTableCommand(tableName string, mainAlias string, tableColumnSelectors TableColumnSelectors, joinList []JoinSelect, whereExpression Expression, groupBy []GroupBySelect, havingExpression Expression, orderBy []OrderBySelect, limit int64, hasLimit bool, offset int64, distinct bool) *SelectTableCommand {
+	return &SelectTableCommand{tableName, mainAlias, tableColumnSelectors, joinList, whereExpression, groupBy, havingExpression, orderBy, limit, hasLimit, offset, distinct}
 }
 
 //SourceTable returns the sourceTable of the table in which the values will be inserted.
@@ -239,6 +385,223 @@ func (s SelectTableCommand) Condition() Expression {
 	return s.whereExpression
 }
 
+//GroupBy returns the columns of the GROUP BY clause.
+func (s SelectTableCommand) GroupBy() []GroupBySelect {
+	return s.groupBy
+}
+
+//Having returns the condition applied to the groups, or nil if there is no HAVING clause.
+func (s SelectTableCommand) Having() Expression {
+	return s.havingExpression
+}
+
+//OrderBy returns the sort keys of the ORDER BY clause.
+func (s SelectTableCommand) OrderBy() []OrderBySelect {
+	return s.orderBy
+}
+
+//Limit returns the maximum number of rows to return and returns true if there is a LIMIT clause.
+func (s SelectTableCommand) Limit() (int64, bool) {
+	return s.limit, s.hasLimit
+}
+
+//Offset returns the number of rows to skip.
+func (s SelectTableCommand) Offset() int64 {
+	return s.offset
+}
+
+//Distinct returns true if duplicate rows are removed from the result.
+func (s SelectTableCommand) Distinct() bool {
+	return s.distinct
+}
+
+//projectedColumnCount returns the number of projected columns and returns false if it depends on a star selector.
+func (s SelectTableCommand) projectedColumnCount() (int, bool) {
+	for _, selector := range s.tableColumnSelectors {
+		switch c := selector.(type) {
+		case *TableColumnStarSelector, TableColumnStarSelector:
+			return 0, false
+		case *TableColumnSelector:
+			if c.isStar {
+				return 0, false
+			}
+		case TableColumnSelector:
+			if c.isStar {
+				return 0, false
+			}
+		}
+	}
+	return len(s.tableColumnSelectors), true
+}
+
+//ValidateHaving verifies that the HAVING clause only references grouped columns outside of aggregates.
+func (s SelectTableCommand) ValidateHaving() error {
+	if s.havingExpression == nil {
+		return nil
+	}
+
+	grouped := map[string]bool{}
+	for _, column := range s.groupBy {
+		grouped[column.key()] = true
+	}
+
+	for _, key := range ungroupedReferences(s.havingExpression) {
+		if !grouped[key] {
+			return UngroupedColumnError{key}
+		}
+	}
+
+	return nil
+}
+
+//SetOperator is used to determine how a CompoundSelectCommand combines its selects.
+type SetOperator int
+
+//Set operator constants.
+const (
+	Union SetOperator = iota
+	Intersect
+	Except
+)
+
+var setOperatorName = map[SetOperator]string{
+	Union:     "UNION",
+	Intersect: "INTERSECT",
+	Except:    "EXCEPT",
+}
+
+func (o SetOperator) String() string {
+	return setOperatorName[o]
+}
+
+//CompoundSelectCommand represents two select queries combined with UNION, INTERSECT or EXCEPT.
+type CompoundSelectCommand struct {
+	left     *SelectTableCommand
+	right    *SelectTableCommand
+	operator SetOperator
+	all      bool
+}
+
+//NewCompoundSelectCommand returns an instance of CompoundSelectCommand.
+func NewCompoundSelectCommand(left *SelectTableCommand, right *SelectTableCommand, operator SetOperator, all bool) *CompoundSelectCommand {
+	return &CompoundSelectCommand{left, right, operator, all}
+}
+
+//TableName returns the source table of the left select.
+func (c CompoundSelectCommand) TableName() string {
+	return c.left.TableName()
+}
+
+func (c CompoundSelectCommand) Left() *SelectTableCommand {
+	return c.left
+}
+
+func (c CompoundSelectCommand) Right() *SelectTableCommand {
+	return c.right
+}
+
+func (c CompoundSelectCommand) Operator() SetOperator {
+	return c.operator
+}
+
+//All returns true if duplicate rows are kept, as in UNION ALL.
+func (c CompoundSelectCommand) All() bool {
+	return c.all
+}
+
+//Validate verifies that both selects project the same number of columns when it is known without a schema.
+func (c CompoundSelectCommand) Validate() error {
+	left, ok1 := c.left.projectedColumnCount()
+	right, ok2 := c.right.projectedColumnCount()
+
+	if ok1 && ok2 && left != right {
+		return SetColumnCountError{c.operator, left, right}
+	}
+	return nil
+}
+
+//Combine applies the set operator to the rows produced by the left and right selects.
+func (c CompoundSelectCommand) Combine(left [][]interface{}, right [][]interface{}) ([][]interface{}, error) {
+	if err := checkSetCompatibility(c.operator, left, right); err != nil {
+		return nil, err
+	}
+
+	rightCounts := map[string]int{}
+	for _, row := range right {
+		rightCounts[rowKey(row)]++
+	}
+
+	result := [][]interface{}{}
+	emitted := map[string]bool{}
+	emit := func(key string, row []interface{}) {
+		if c.all || !emitted[key] {
+			emitted[key] = true
+			result = append(result, row)
+		}
+	}
+
+	for _, row := range left {
+		key := rowKey(row)
+
+		switch c.operator {
+		case Union:
+			emit(key, row)
+		case Intersect:
+			if rightCounts[key] > 0 {
+				if c.all {
+					rightCounts[key]--
+				}
+				emit(key, row)
+			}
+		case Except:
+			if rightCounts[key] > 0 {
+				if c.all {
+					rightCounts[key]--
+				}
+				continue
+			}
+			emit(key, row)
+		}
+	}
+
+	if c.operator == Union {
+		for _, row := range right {
+			emit(rowKey(row), row)
+		}
+	}
+
+	return result, nil
+}
+
+//checkSetCompatibility verifies that every row has the same number of columns and that values
+//in the same column are of compatible types.
+func checkSetCompatibility(operator SetOperator, left [][]interface{}, right [][]interface{}) error {
+	rows := append(append([][]interface{}{}, left...), right...)
+	if len(rows) == 0 {
+		return nil
+	}
+
+	columns := make([]interface{}, len(rows[0]))
+	for _, row := range rows {
+		if len(row) != len(columns) {
+			return SetColumnCountError{operator, len(columns), len(row)}
+		}
+
+		for i, value := range row {
+			switch {
+			case value == nil:
+			case columns[i] == nil:
+				columns[i] = value
+			case isNumeric(columns[i]) && isNumeric(value):
+			case reflect.TypeOf(columns[i]) != reflect.TypeOf(value):
+				return newOperatorTypeError(operator.String(), columns[i], value)
+			}
+		}
+	}
+
+	return nil
+}
+
 //InsertCommand represents an insert statement.
 type InsertCommand struct {
 	tableName string
@@ -260,6 +623,26 @@ func (i InsertCommand) Values() map[string]interface{} {
 	return i.values
 }
 
+//CheckNullable verifies that every non nullable column receives a value, either explicitly or through its default value.
+func (i InsertCommand) CheckNullable(tableColumnDefiners TableColumnDefiners) error {
+	for _, definer := range tableColumnDefiners {
+		if definer.Nullable() || definer.Autoincrementable() {
+			continue
+		}
+
+		value, ok := i.values[definer.ColumnName()]
+		if !ok {
+			value = definer.DefaultValue()
+		}
+
+		if value == nil {
+			return NotNullViolationError{definer.ColumnName()}
+		}
+	}
+
+	return nil
+}
+
 //DropCommand represents an drop statement.
 type DropCommand struct {
 	tableName string
@@ -286,6 +669,16 @@ func NewAlterCommand(tableName string, instruction interface{}) *AlterCommand {
 	return &AlterCommand{tableName, instruction}
 }
 
+//TableName returns the name of the table to be altered.
+func (a AlterCommand) TableName() string {
+	return a.table
+}
+
+//Instruction returns the *AlterDropInst, *AlterAddInst or *AlterModifyInst applied to the table.
+func (a AlterCommand) Instruction() interface{} {
+	return a.instruction
+}
+
 //AlterDropInst represents an alter drop instruction.
 type AlterDropInst struct {
 	table string
@@ -296,6 +689,11 @@ func NewAlterDropInst(tableName string) *AlterDropInst {
 	return &AlterDropInst{tableName}
 }
 
+//ColumnName returns the name of the column to be dropped.
+func (a AlterDropInst) ColumnName() string {
+	return a.table
+}
+
 //AlterAddInst represents an alter add instruction.
 type AlterAddInst struct {
 	tableColumnDefiners TableColumnDefiner
@@ -306,6 +704,11 @@ func NewAlterAddInst(tableColumnDefiners TableColumnDefiner) *AlterAddInst {
 	return &AlterAddInst{tableColumnDefiners}
 }
 
+//TableColumnDefiner returns the definition of the column to be added.
+func (a AlterAddInst) TableColumnDefiner() TableColumnDefiner {
+	return a.tableColumnDefiners
+}
+
 //AlterModifyInst represents an modify add instruction.
 type AlterModifyInst struct {
 	tableColumnDefiners TableColumnDefiner
@@ -316,6 +719,11 @@ func NewAlterModifyInst(tableColumnDefiners TableColumnDefiner) *AlterModifyInst
 	return &AlterModifyInst{tableColumnDefiners}
 }
 
+//TableColumnDefiner returns the new definition of the modified column.
+func (a AlterModifyInst) TableColumnDefiner() TableColumnDefiner {
+	return a.tableColumnDefiners
+}
+
 type UpdateTableCommand struct {
 	tableName   string
 	assignments []*AssignmentCommon
@@ -332,6 +740,21 @@ func (c UpdateTableCommand) Values(symbols map[string]interface{}) map[string]in
 	return values
 }
 
+//ValuesE is like Values but returns the first evaluation error instead of panicking.
+func (c UpdateTableCommand) ValuesE(symbols map[string]interface{}) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+
+	for _, assignment := range c.assignments {
+		value, err := assignment.expression.EvaluateE(symbols)
+		if err != nil {
+			return nil, err
+		}
+		values[assignment.value] = value
+	}
+
+	return values, nil
+}
+
 func (c UpdateTableCommand) TableName() string {
 	return c.tableName
 }
@@ -365,6 +788,11 @@ func (c DeleteCommand) Condition() Expression {
 	return c.where
 }
 
+//Alias returns the alias of the table and returns true if it isn't empty.
+func (c DeleteCommand) Alias() (string, bool) {
+	return c.alias, len(c.alias) > 0
+}
+
 //Instruction executes the command.
 type Instruction func()
 
@@ -380,16 +808,18 @@ const (
 	Delete
 	Drop
 	Alter
+	CompoundSelect
 )
 
 var instructionName = map[InstructionType]string{
-	Create: "CREATE",
-	Select: "SELECT",
-	Update: "UPDATE",
-	Insert: "INSERT",
-	Delete: "DELETE",
-	Drop:   "DROP",
-	Alter:  "ALTER",
+	Create:         "CREATE",
+	Select:         "SELECT",
+	Update:         "UPDATE",
+	Insert:         "INSERT",
+	Delete:         "DELETE",
+	Drop:           "DROP",
+	Alter:          "ALTER",
+	CompoundSelect: "COMPOUND SELECT",
 }
 
 func (i InstructionType) String() string {
@@ -407,7 +837,11 @@ func NewCommand(tableModifier tableModifier, instructionType InstructionType, in
 	return Command{tableModifier, instructionType, instruction}
 }
 
+//String renders the command as SQL.
 func (c Command) String() string {
+	if stringer, ok := c.tableModifier.(fmt.Stringer); ok {
+		return stringer.String()
+	}
 	return fmt.Sprintf("%s %s", c.InstructionType.String(), c.tableModifier.TableName())
 
 }
@@ -0,0 +1,202 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+)
+
+//groupedSelect returns a select of table t grouped by the given columns with a HAVING condition.
+func groupedSelect(having Expression, groupBy ...GroupBySelect) *SelectTableCommand {
+	return NewSelectTableCommand("t", "", nil, nil, nil, groupBy, having, nil, 0, false, 0, false)
+}
+
+func TestValidateHaving(t *testing.T) {
+	d, qualified := *NewGroupBySelect("", "d"), *NewGroupBySelect("t", "d")
+	count := NewAggregateCommon("count", nil, false)
+
+	tests := []struct {
+		name  string
+		query *SelectTableCommand
+		err   error
+	}{
+		{"no having", groupedSelect(nil), nil},
+		{"aggregate", groupedSelect(NewGtCommon(NewIntCommon(1), count)), nil},
+		{"aggregated column", groupedSelect(NewGtCommon(NewIntCommon(1), NewAggregateCommon("sum", NewIdCommon("x", ""), false)), d), nil},
+		{"grouped column", groupedSelect(NewAndCommon(NewEqCommon(NewStringCommon("a"), NewIdCommon("d", "")), NewGtCommon(NewIntCommon(1), count)), d), nil},
+		{"qualified grouped column", groupedSelect(NewIsNullCommon(NewIdCommon("t", "d"), true), qualified), nil},
+		{"ungrouped column", groupedSelect(NewGtCommon(NewIntCommon(1), NewIdCommon("x", "")), d), UngroupedColumnError{"x"}},
+		{"ungrouped qualifier", groupedSelect(NewIsNullCommon(NewIdCommon("t", "d"), false), d), UngroupedColumnError{"t.d"}},
+		{"without group by", groupedSelect(NewIsNullCommon(NewIdCommon("d", ""), false)), UngroupedColumnError{"d"}},
+	}
+
+	for _, test := range tests {
+		if err := test.query.ValidateHaving(); err != test.err {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestFilterGroups(t *testing.T) {
+	count := NewAggregateCommon("count", nil, false)
+	rows := NewSliceIterator([]map[string]interface{}{{"d": "a"}, {"d": "b"}, {"d": "a"}, {"d": nil}, {"d": nil}})
+
+	grouped, err := GroupRows(rows, []GroupBySelect{*NewGroupBySelect("", "d")}, []*AggregateCommon{count})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	having := NewGtCommon(NewIntCommon(1), count)
+	if err := groupedSelect(having, *NewGroupBySelect("", "d")).ValidateHaving(); err != nil {
+		t.Fatal(err)
+	}
+
+	filtered, err := FilterGroups(grouped, having)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filtered) != 2 || filtered[0]["d"] != "a" || filtered[1]["d"] != nil {
+		t.Errorf("expected the groups a and NULL, got %v", filtered)
+	}
+
+	filtered, err = FilterGroups(grouped, NewEqCommon(NewStringCommon("a"), NewIdCommon("d", "")))
+	if err != nil || len(filtered) != 1 {
+		t.Errorf("expected the NULL group to be filtered out as UNKNOWN, got %v (%v)", filtered, err)
+	}
+
+	if filtered, err = FilterGroups(grouped, nil); err != nil || len(filtered) != len(grouped) {
+		t.Errorf("expected every group without HAVING, got %v (%v)", filtered, err)
+	}
+
+	if _, err := FilterGroups(grouped, NewGtCommon(NewIntCommon(1), NewAggregateCommon("sum", NewIdCommon("x", ""), false))); err == nil {
+		t.Error("expected an error for an aggregate that wasn't computed")
+	}
+}
+
+func TestDistinctRows(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"a": int64(1), "b": "x"},
+		{"a": 1.0, "b": "x"},
+		{"a": int64(1), "b": nil},
+		{"a": int64(1), "b": nil},
+		{"a": "1", "b": "x"},
+		{"a": int64(1), "b": "x", "c": nil},
+	}
+
+	distinct := DistinctRows(rows)
+	if len(distinct) != 4 || distinct[0]["a"] != int64(1) || distinct[1]["b"] != nil || distinct[2]["a"] != "1" {
+		t.Errorf("expected 4 rows keeping the first occurrences, got %v", distinct)
+	}
+}
+
+func TestCompoundSelectCombine(t *testing.T) {
+	left := [][]interface{}{{int64(1)}, {int64(2)}, {int64(2)}, {nil}, {int64(3)}}
+	right := [][]interface{}{{2.0}, {nil}, {int64(4)}, {int64(4)}}
+
+	tests := []struct {
+		operator SetOperator
+		all      bool
+		expected string
+	}{
+		{Union, false, "[[1] [2] [<nil>] [3] [4]]"},
+		{Union, true, "[[1] [2] [2] [<nil>] [3] [2] [<nil>] [4] [4]]"},
+		{Intersect, false, "[[2] [<nil>]]"},
+		{Intersect, true, "[[2] [<nil>]]"},
+		{Except, false, "[[1] [3]]"},
+		{Except, true, "[[1] [2] [3]]"},
+	}
+
+	query := groupedSelect(nil)
+	for _, test := range tests {
+		compound := NewCompoundSelectCommand(query, query, test.operator, test.all)
+
+		combined, err := compound.Combine(left, right)
+		if err != nil {
+			t.Errorf("%s (ALL %v): unexpected error %v", test.operator, test.all, err)
+			continue
+		}
+		if result := fmt.Sprint(combined); result != test.expected {
+			t.Errorf("%s (ALL %v): expected %s, got %s", test.operator, test.all, test.expected, result)
+		}
+	}
+}
+
+func TestCompoundSelectCompatibility(t *testing.T) {
+	query := groupedSelect(nil)
+	compound := NewCompoundSelectCommand(query, query, Union, false)
+
+	if _, err := compound.Combine([][]interface{}{{int64(1)}}, [][]interface{}{{int64(1), int64(2)}}); err != (SetColumnCountError{Union, 1, 2}) {
+		t.Errorf("expected a SetColumnCountError, got %v", err)
+	}
+	if _, err := compound.Combine([][]interface{}{{int64(1)}}, [][]interface{}{{"1"}}); err == nil {
+		t.Error("expected an error combining an integer column with a string column")
+	}
+	if _, err := compound.Combine([][]interface{}{{nil}}, [][]interface{}{{"1"}, {nil}}); err != nil {
+		t.Errorf("unexpected error combining NULL with a string column: %v", err)
+	}
+
+	column := func(name string) *TableColumnSelector {
+		return NewTableColumnSelector(false, "", name, "", nil)
+	}
+	oneColumn := NewSelectTableCommand("t", "", TableColumnSelectors{column("a")}, nil, nil, nil, nil, nil, 0, false, 0, false)
+	twoColumns := NewSelectTableCommand("t", "", TableColumnSelectors{column("a"), column("b")}, nil, nil, nil, nil, nil, 0, false, 0, false)
+	star := NewSelectTableCommand("t", "", TableColumnSelectors{NewTableColumnStarSelector()}, nil, nil, nil, nil, nil, 0, false, 0, false)
+
+	if err := NewCompoundSelectCommand(oneColumn, twoColumns, Except, false).Validate(); err != (SetColumnCountError{Except, 1, 2}) {
+		t.Errorf("expected a SetColumnCountError, got %v", err)
+	}
+	if err := NewCompoundSelectCommand(star, twoColumns, Except, false).Validate(); err != nil {
+		t.Errorf("expected a star select to be validated against the rows, got %v", err)
+	}
+}
+
+func TestJoinSelectKinds(t *testing.T) {
+	tests := []struct {
+		kind                          JoinKind
+		preservesLeft, preservesRight bool
+	}{
+		{InnerJoin, false, false},
+		{LeftJoin, true, false},
+		{RightJoin, false, true},
+		{FullJoin, true, true},
+		{CrossJoin, false, false},
+	}
+
+	for _, test := range tests {
+		join := NewJoinSelectWithKind(test.kind, "b", "", nil, nil)
+		if join.PreservesLeft() != test.preservesLeft || join.PreservesRight() != test.preservesRight {
+			t.Errorf("%s: expected preserved sides %v and %v, got %v and %v", test.kind, test.preservesLeft, test.preservesRight, join.PreservesLeft(), join.PreservesRight())
+		}
+	}
+
+	if join := NewJoinSelect("b", "", nil); join.Kind() != InnerJoin || join.Qualifier() != "b" {
+		t.Errorf("expected an INNER JOIN qualified by its table, got %s qualified by %s", join.Kind(), join.Qualifier())
+	}
+}
+
+func TestJoinSelectCondition(t *testing.T) {
+	on := NewGtCommon(NewIntCommon(1), NewIdCommon("bb", "x"))
+
+	tests := []struct {
+		join          *JoinSelect
+		leftQualifier string
+		expected      string
+	}{
+		{NewJoinSelect("b", "bb", on), "a", "bb.x > 1"},
+		{NewJoinSelectWithKind(LeftJoin, "b", "", nil, []string{"id"}), "a", "a.id = b.id"},
+		{NewJoinSelectWithKind(FullJoin, "b", "bb", on, []string{"id", "k"}), "a", "bb.x > 1 AND a.id = bb.id AND a.k = bb.k"},
+		{NewJoinSelectWithKind(RightJoin, "b", "bb", nil, []string{"id"}), "", "id = bb.id"},
+	}
+
+	for _, test := range tests {
+		if condition := fmt.Sprint(test.join.Condition(test.leftQualifier)); condition != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.join.Kind(), test.expected, condition)
+		}
+	}
+
+	if condition := NewJoinSelectWithKind(CrossJoin, "b", "", on, []string{"id"}).Condition("a"); condition != nil {
+		t.Errorf("expected no condition for a CROSS JOIN, got %v", condition)
+	}
+	if row := NewJoinSelect("b", "bb", nil).NullRow([]string{"id", "x"}); fmt.Sprint(row) != "map[bb.id:<nil> bb.x:<nil>]" {
+		t.Errorf("expected the NULL padding of bb, got %v", row)
+	}
+}
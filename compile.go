@@ -0,0 +1,581 @@
+package common
+
+import "reflect"
+
+//CompiledExpression evaluates an expression against a row whose values are positioned by a RowLayout.
+type CompiledExpression func(row []interface{}) (interface{}, error)
+
+//RowLayout maps the identifiers read by IdCommon to positions of a row slice. The optional schema
+//lets the compiler specialize operators on the static types of their operands.
+type RowLayout struct {
+	columns []string
+	slots   map[string]int
+	schema  Schema
+}
+
+//NewRowLayout creates an instance of RowLayout where each column is read from the same position of the row.
+func NewRowLayout(columns []string, schema Schema) *RowLayout {
+	slots := make(map[string]int, len(columns))
+	for i, column := range columns {
+		slots[column] = i
+	}
+	return &RowLayout{columns, slots, schema}
+}
+
+//Columns returns the identifiers in the order of their positions.
+func (l RowLayout) Columns() []string {
+	return l.columns
+}
+
+func (l RowLayout) symbols(row []interface{}) map[string]interface{} {
+	symbols := make(map[string]interface{}, len(l.columns))
+	for i, column := range l.columns {
+		symbols[column] = row[i]
+	}
+	return symbols
+}
+
+//compiler compiles the nodes of an expression against a layout, using the static types inferred
+//for them from the schema of the layout.
+type compiler struct {
+	layout *RowLayout
+	types  map[Expression]Type
+}
+
+func (c compiler) staticType(expression Expression) Type {
+	if c.types == nil || reflect.ValueOf(expression).Kind() != reflect.Ptr {
+		return UnknownType
+	}
+	return c.types[expression]
+}
+
+//Compile resolves the identifiers of an expression to positions of the layout once and returns a closure
+//evaluating it. Operators whose operands are statically known to be INTEGER or FLOAT skip the type switches
+//of the tree walker, falling back to them if a value doesn't match its declared type. Function calls are
+//resolved when compiled. Aggregates and subqueries are evaluated by EvaluateE over the values of the row.
+func Compile(expression Expression, layout *RowLayout) (CompiledExpression, error) {
+	c := compiler{layout: layout}
+	if layout.schema != nil {
+		c.types = inferTypes(expression, layout.schema)
+	}
+	return c.compile(expression)
+}
+
+func (c compiler) compile(expression Expression) (CompiledExpression, error) {
+	if value, ok := constantValue(expression); ok {
+		return func([]interface{}) (interface{}, error) {
+			return value, nil
+		}, nil
+	}
+
+	switch e := expression.(type) {
+	case *IdCommon:
+		slot, ok := c.layout.slots[e.Key()]
+		if !ok {
+			return nil, UnknownIdentifierError{e.Key()}
+		}
+		return func(row []interface{}) (interface{}, error) {
+			return row[slot], nil
+		}, nil
+	case *SumCommon:
+		return c.compileArithmetic("+", e.leftValue, e.rightValue)
+	case *SubCommon:
+		return c.compileArithmetic("-", e.leftValue, e.rightValue)
+	case *MultCommon:
+		return c.compileArithmetic("*", e.leftValue, e.rightValue)
+	case *DivCommon:
+		return c.compileArithmetic("/", e.leftValue, e.rightValue)
+	case *EqCommon:
+		return c.compileEquality(false, e.leftValue, e.rightValue)
+	case *NeCommon:
+		return c.compileEquality(true, e.leftValue, e.rightValue)
+	case *LtCommon:
+		return c.compileComparison("<", e.leftValue, e.rightValue, func(result int) bool { return result < 0 })
+	case *GtCommon:
+		return c.compileComparison(">", e.leftValue, e.rightValue, func(result int) bool { return result > 0 })
+	case *LteCommon:
+		return c.compileComparison("<=", e.leftValue, e.rightValue, func(result int) bool { return result <= 0 })
+	case *GteCommon:
+		return c.compileComparison(">=", e.leftValue, e.rightValue, func(result int) bool { return result >= 0 })
+	case *AndCommon:
+		return c.compileLogical("AND", false, e.leftValue, e.rightValue)
+	case *OrCommon:
+		return c.compileLogical("OR", true, e.leftValue, e.rightValue)
+	case *NotCommon:
+		return c.compileNot(e.not)
+	case *IsNullCommon:
+		return c.compileIsNull(e)
+	case *IsBoolCommon:
+		return c.compileIsBool(e)
+	case *IsDistinctFromCommon:
+		return c.compileIsDistinctFrom(e)
+	case *BetweenCommon:
+		return c.compileBetween(e)
+	case *LikeCommon:
+		return c.compileLike(e)
+	case *InCommon:
+		return c.compileIn(e)
+	case *CaseCommon:
+		return c.compileCase(e)
+	case *FuncCallCommon:
+		return c.compileFuncCall(e)
+	}
+
+	for _, reference := range ungroupedReferences(expression) {
+		if _, ok := c.layout.slots[reference]; !ok {
+			return nil, UnknownIdentifierError{reference}
+		}
+	}
+
+	return func(row []interface{}) (interface{}, error) {
+		return expression.EvaluateE(c.layout.symbols(row))
+	}, nil
+}
+
+//compileAll compiles a list of expressions in order.
+func (c compiler) compileAll(expressions ...Expression) ([]CompiledExpression, error) {
+	compiled := make([]CompiledExpression, len(expressions))
+	for i, expression := range expressions {
+		var err error
+		if compiled[i], err = c.compile(expression); err != nil {
+			return nil, err
+		}
+	}
+	return compiled, nil
+}
+
+func (c compiler) compileOperands(left Expression, right Expression) (CompiledExpression, CompiledExpression, error) {
+	l, err := c.compile(left)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, err := c.compile(right)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return l, r, nil
+}
+
+func (c compiler) compileArithmetic(operator string, left Expression, right Expression) (CompiledExpression, error) {
+	l, r, err := c.compileOperands(left, right)
+	if err != nil {
+		return nil, err
+	}
+
+	leftType, rightType := c.staticType(left), c.staticType(right)
+
+	switch {
+	case leftType == IntegerType && rightType == IntegerType:
+		op := integerOperator(operator)
+		return func(row []interface{}) (interface{}, error) {
+			a, b, err := evaluateCompiled(row, l, r)
+			if err != nil || a == nil || b == nil {
+				return nil, err
+			}
+			x, ok1 := a.(int64)
+			y, ok2 := b.(int64)
+			if !(ok1 && ok2) {
+				return arithmetic(operator, a, b)
+			}
+			return op(x, y)
+		}, nil
+	case leftType == FloatType && rightType == FloatType:
+		op := floatOperator(operator)
+		return func(row []interface{}) (interface{}, error) {
+			a, b, err := evaluateCompiled(row, l, r)
+			if err != nil || a == nil || b == nil {
+				return nil, err
+			}
+			x, ok1 := a.(float64)
+			y, ok2 := b.(float64)
+			if !(ok1 && ok2) {
+				return arithmetic(operator, a, b)
+			}
+			return op(x, y)
+		}, nil
+	}
+
+	return func(row []interface{}) (interface{}, error) {
+		a, b, err := evaluateCompiled(row, l, r)
+		if err != nil {
+			return nil, err
+		}
+		return arithmetic(operator, a, b)
+	}, nil
+}
+
+func integerOperator(operator string) func(int64, int64) (interface{}, error) {
+	switch operator {
+	case "+":
+		return func(a, b int64) (interface{}, error) { return a + b, nil }
+	case "-":
+		return func(a, b int64) (interface{}, error) { return a - b, nil }
+	case "*":
+		return func(a, b int64) (interface{}, error) { return a * b, nil }
+	}
+	return func(a, b int64) (interface{}, error) { return integerArithmetic(operator, a, b) }
+}
+
+func floatOperator(operator string) func(float64, float64) (interface{}, error) {
+	switch operator {
+	case "+":
+		return func(a, b float64) (interface{}, error) { return a + b, nil }
+	case "-":
+		return func(a, b float64) (interface{}, error) { return a - b, nil }
+	case "*":
+		return func(a, b float64) (interface{}, error) { return a * b, nil }
+	}
+	return func(a, b float64) (interface{}, error) { return floatArithmetic(operator, a, b) }
+}
+
+func (c compiler) compileEquality(negate bool, left Expression, right Expression) (CompiledExpression, error) {
+	l, r, err := c.compileOperands(left, right)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.staticType(left) == IntegerType && c.staticType(right) == IntegerType {
+		return func(row []interface{}) (interface{}, error) {
+			a, b, err := evaluateCompiled(row, l, r)
+			if err != nil || a == nil || b == nil {
+				return nil, err
+			}
+			x, ok1 := a.(int64)
+			y, ok2 := b.(int64)
+			if !(ok1 && ok2) {
+				return equalValues(a, b) != negate, nil
+			}
+			return (x == y) != negate, nil
+		}, nil
+	}
+
+	return func(row []interface{}) (interface{}, error) {
+		a, b, err := evaluateCompiled(row, l, r)
+		if err != nil || a == nil || b == nil {
+			return nil, err
+		}
+		return equalValues(a, b) != negate, nil
+	}, nil
+}
+
+func (c compiler) compileComparison(operator string, left Expression, right Expression, test func(int) bool) (CompiledExpression, error) {
+	l, r, err := c.compileOperands(left, right)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.staticType(left) == IntegerType && c.staticType(right) == IntegerType {
+		return func(row []interface{}) (interface{}, error) {
+			a, b, err := evaluateCompiled(row, l, r)
+			if err != nil || a == nil || b == nil {
+				return nil, err
+			}
+			x, ok1 := a.(int64)
+			y, ok2 := b.(int64)
+			if !(ok1 && ok2) {
+				return comparison(operator, a, b, test)
+			}
+			return test(compareIntegers(x, y)), nil
+		}, nil
+	}
+
+	return func(row []interface{}) (interface{}, error) {
+		a, b, err := evaluateCompiled(row, l, r)
+		if err != nil {
+			return nil, err
+		}
+		return comparison(operator, a, b, test)
+	}, nil
+}
+
+//compileLogical compiles AND and OR with the same short-circuit evaluation as evaluateLogical.
+func (c compiler) compileLogical(operator string, dominant bool, left Expression, right Expression) (CompiledExpression, error) {
+	l, r, err := c.compileOperands(left, right)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(row []interface{}) (interface{}, error) {
+		a, err := l(row)
+		if err != nil {
+			return nil, err
+		}
+
+		aTruth, aKnown, err := toTruth(operator, a)
+		if err != nil {
+			return nil, err
+		}
+		if aKnown && aTruth == dominant {
+			return dominant, nil
+		}
+
+		b, err := r(row)
+		if err != nil {
+			return nil, err
+		}
+		return logicalResult(operator, dominant, aKnown, b)
+	}, nil
+}
+
+func (c compiler) compileNot(operand Expression) (CompiledExpression, error) {
+	compiled, err := c.compile(operand)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(row []interface{}) (interface{}, error) {
+		value, err := compiled(row)
+		if err != nil {
+			return nil, err
+		}
+
+		truth, known, err := toTruth("NOT", value)
+		if err != nil || !known {
+			return nil, err
+		}
+		return !truth, nil
+	}, nil
+}
+
+func (c compiler) compileIsNull(isNull *IsNullCommon) (CompiledExpression, error) {
+	compiled, err := c.compile(isNull.value)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(row []interface{}) (interface{}, error) {
+		value, err := compiled(row)
+		if err != nil {
+			return nil, err
+		}
+		return (value == nil) != isNull.not, nil
+	}, nil
+}
+
+func (c compiler) compileIsBool(isBool *IsBoolCommon) (CompiledExpression, error) {
+	compiled, err := c.compile(isBool.value)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(row []interface{}) (interface{}, error) {
+		value, err := compiled(row)
+		if err != nil {
+			return nil, err
+		}
+		return isBool.test(value)
+	}, nil
+}
+
+func (c compiler) compileIsDistinctFrom(isDistinct *IsDistinctFromCommon) (CompiledExpression, error) {
+	l, r, err := c.compileOperands(isDistinct.leftValue, isDistinct.rightValue)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(row []interface{}) (interface{}, error) {
+		a, b, err := evaluateCompiled(row, l, r)
+		if err != nil {
+			return nil, err
+		}
+		return isDistinct.test(a, b), nil
+	}, nil
+}
+
+func (c compiler) compileBetween(between *BetweenCommon) (CompiledExpression, error) {
+	operands, err := c.compileAll(between.value, between.lower, between.upper)
+	if err != nil {
+		return nil, err
+	}
+	value, lower, upper := operands[0], operands[1], operands[2]
+
+	return func(row []interface{}) (interface{}, error) {
+		v, err := value(row)
+		if err != nil {
+			return nil, err
+		}
+
+		l, u, err := evaluateCompiled(row, lower, upper)
+		if err != nil {
+			return nil, err
+		}
+		return between.test(v, l, u)
+	}, nil
+}
+
+func (c compiler) compileLike(like *LikeCommon) (CompiledExpression, error) {
+	l, r, err := c.compileOperands(like.leftValue, like.rightValue)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(row []interface{}) (interface{}, error) {
+		a, b, err := evaluateCompiled(row, l, r)
+		if err != nil {
+			return nil, err
+		}
+		return like.match(a, b)
+	}, nil
+}
+
+//compileIn compiles an IN whose list is evaluated in order, like InCommon, without collecting the candidates.
+func (c compiler) compileIn(in *InCommon) (CompiledExpression, error) {
+	value, err := c.compile(in.value)
+	if err != nil {
+		return nil, err
+	}
+
+	if in.set != nil {
+		return func(row []interface{}) (interface{}, error) {
+			v, err := value(row)
+			if err != nil {
+				return nil, err
+			}
+			return in.lookup(v), nil
+		}, nil
+	}
+
+	candidates, err := c.compileAll(in.list...)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(row []interface{}) (interface{}, error) {
+		v, err := value(row)
+		if err != nil {
+			return nil, err
+		}
+
+		matched, hasNull := false, false
+		for _, candidate := range candidates {
+			x, err := candidate(row)
+			if err != nil {
+				return nil, err
+			}
+
+			switch {
+			case x == nil:
+				hasNull = true
+			case v != nil && !matched:
+				matched = equalValues(v, x)
+			}
+		}
+
+		switch {
+		case len(candidates) == 0:
+			return in.not, nil
+		case v == nil:
+			return nil, nil
+		case matched:
+			return !in.not, nil
+		}
+		return inResult(in.not, hasNull), nil
+	}, nil
+}
+
+func (c compiler) compileCase(expression *CaseCommon) (CompiledExpression, error) {
+	var operand CompiledExpression
+	if expression.operand != nil {
+		var err error
+		if operand, err = c.compile(expression.operand); err != nil {
+			return nil, err
+		}
+	}
+
+	conditions := make([]CompiledExpression, len(expression.whens))
+	results := make([]CompiledExpression, len(expression.whens))
+	for i, when := range expression.whens {
+		var err error
+		if conditions[i], results[i], err = c.compileOperands(when.condition, when.result); err != nil {
+			return nil, err
+		}
+	}
+
+	var elseValue CompiledExpression
+	if expression.elseValue != nil {
+		var err error
+		if elseValue, err = c.compile(expression.elseValue); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(row []interface{}) (interface{}, error) {
+		var value interface{}
+		if operand != nil {
+			var err error
+			if value, err = operand(row); err != nil {
+				return nil, err
+			}
+		}
+
+		for i, condition := range conditions {
+			x, err := condition(row)
+			if err != nil {
+				return nil, err
+			}
+
+			matched := matchesOperand(value, x)
+			if operand == nil {
+				if matched, _, err = toTruth("WHERE", x); err != nil {
+					return nil, err
+				}
+			}
+
+			if matched {
+				return expression.unify(results[i](row))
+			}
+		}
+
+		if elseValue == nil {
+			return nil, nil
+		}
+		return expression.unify(elseValue(row))
+	}, nil
+}
+
+func (c compiler) compileFuncCall(call *FuncCallCommon) (CompiledExpression, error) {
+	args, err := c.compileAll(call.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	function, err := call.resolve()
+	if err != nil {
+		return func([]interface{}) (interface{}, error) {
+			return nil, err
+		}, nil
+	}
+
+	return func(row []interface{}) (interface{}, error) {
+		values := make([]interface{}, len(args))
+		for i, arg := range args {
+			value, err := arg(row)
+			if err != nil {
+				return nil, err
+			}
+
+			if value == nil && function.NullOnNull {
+				return nil, nil
+			}
+			values[i] = value
+		}
+
+		return function.Call(values)
+	}, nil
+}
+
+func evaluateCompiled(row []interface{}, left CompiledExpression, right CompiledExpression) (interface{}, interface{}, error) {
+	a, err := left(row)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b, err := right(row)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return a, b, nil
+}
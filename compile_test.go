@@ -0,0 +1,166 @@
+package common
+
+import "testing"
+
+type evaluation struct {
+	value interface{}
+	err   error
+}
+
+//evaluateBoth evaluates an expression with EvaluateE and with Compile over the same row.
+func evaluateBoth(t *testing.T, expression Expression, layout *RowLayout, row []interface{}) (evaluation, evaluation) {
+	compiled, err := Compile(expression, layout)
+	if err != nil {
+		t.Fatalf("%v: %v", expression, err)
+	}
+
+	var tree, closure evaluation
+	tree.value, tree.err = expression.EvaluateE(layout.symbols(row))
+	closure.value, closure.err = compiled(row)
+	return tree, closure
+}
+
+func TestLogicalShortCircuit(t *testing.T) {
+	layout := NewRowLayout([]string{"a"}, Schema{"a": IntegerType})
+	divisionByZero := NewEqCommon(NewIntCommon(1), NewDivCommon(NewIntCommon(0), NewIdCommon("a", "")))
+
+	tests := []struct {
+		expression Expression
+		expected   interface{}
+		err        error
+	}{
+		{NewAndCommon(divisionByZero, NewFalseCommon()), false, nil},
+		{NewOrCommon(divisionByZero, NewTrueCommon()), true, nil},
+		{NewAndCommon(divisionByZero, NewTrueCommon()), nil, ErrDivisionByZero},
+		{NewOrCommon(divisionByZero, NewFalseCommon()), nil, ErrDivisionByZero},
+		{NewAndCommon(divisionByZero, NewNullCommon()), nil, ErrDivisionByZero},
+	}
+
+	for _, test := range tests {
+		tree, closure := evaluateBoth(t, test.expression, layout, []interface{}{int64(2)})
+
+		if tree.value != test.expected || tree.err != test.err {
+			t.Errorf("%v: EvaluateE returned %v, %v", test.expression, tree.value, tree.err)
+		}
+		if closure.value != test.expected || closure.err != test.err {
+			t.Errorf("%v: compiled expression returned %v, %v", test.expression, closure.value, closure.err)
+		}
+
+		if optimized, err := Optimize(test.expression).EvaluateE(layout.symbols([]interface{}{int64(2)})); test.err == nil && (optimized != test.expected || err != nil) {
+			t.Errorf("%v: optimized expression returned %v, %v", test.expression, optimized, err)
+		}
+	}
+}
+
+func compiledExpressions() []Expression {
+	a, b, f, s := NewIdCommon("a", ""), NewIdCommon("b", ""), NewIdCommon("f", ""), NewIdCommon("s", "")
+
+	return []Expression{
+		NewSumCommon(b, a),
+		NewSubCommon(NewIntCommon(1), a),
+		NewMultCommon(f, a),
+		NewDivCommon(b, a),
+		NewDivCommon(f, b),
+		NewEqCommon(b, a),
+		NewNeCommon(f, a),
+		NewLtCommon(NewIntCommon(10), NewSumCommon(b, a)),
+		NewGtCommon(f, b),
+		NewLteCommon(s, s),
+		NewGteCommon(NewFloatCommon(2.5), f),
+		NewAndCommon(NewGtCommon(NewIntCommon(0), b), NewLtCommon(NewIntCommon(5), a)),
+		NewOrCommon(NewIsNullCommon(b, false), NewEqCommon(NewIntCommon(3), a)),
+		NewNotCommon(NewEqCommon(b, a)),
+		NewIsNullCommon(f, true),
+		NewInCommon(a, []Expression{NewIntCommon(1), b}, false),
+		NewInCommon(a, []Expression{NewIntCommon(1), NewIntCommon(3)}, true),
+		NewInCommon(f, []Expression{a, NewNullCommon()}, false),
+		NewInCommon(b, []Expression{}, false),
+		NewSumCommon(s, a),
+		NewBetweenCommon(a, NewIntCommon(0), b, false, false),
+		NewBetweenCommon(f, b, a, true, true),
+		NewLikeCommon(NewStringCommon("x%"), s),
+		NewILikeCommon(NewStringCommon("X"), s),
+		NewIsBoolCommon(NewGtCommon(b, a), true, false),
+		NewIsDistinctFromCommon(b, a, false),
+		NewIsDistinctFromCommon(f, a, true),
+		NewCaseCommon(nil, []*WhenCommon{NewWhenCommon(NewGtCommon(NewIntCommon(0), a), b)}, NewFloatCommon(0.5)),
+		NewCaseCommon(a, []*WhenCommon{NewWhenCommon(b, NewStringCommon("same")), NewWhenCommon(NewIntCommon(3), s)}, nil),
+		NewCaseCommon(nil, []*WhenCommon{NewWhenCommon(s, a)}, nil),
+		NewFuncCallCommon("abs", []Expression{NewSubCommon(b, a)}),
+		NewFuncCallCommon("upper", []Expression{s}),
+		NewFuncCallCommon("round", []Expression{f, NewIntCommon(1)}),
+		NewFuncCallCommon("missing", nil),
+	}
+}
+
+func compiledRows() [][]interface{} {
+	return [][]interface{}{
+		{int64(3), int64(4), 2.5, "x"},
+		{int64(0), int64(0), 0.0, ""},
+		{nil, int64(1), nil, nil},
+		{int64(7), nil, 1.5, "y"},
+		{2.0, int64(2), int64(2), "z"},
+	}
+}
+
+func TestCompiledMatchesEvaluateE(t *testing.T) {
+	columns := []string{"a", "b", "f", "s"}
+	layouts := map[string]*RowLayout{
+		"untyped": NewRowLayout(columns, nil),
+		"typed":   NewRowLayout(columns, Schema{"a": IntegerType, "b": IntegerType, "f": FloatType, "s": CharType}),
+	}
+
+	for name, layout := range layouts {
+		for _, expression := range compiledExpressions() {
+			for _, row := range compiledRows() {
+				tree, closure := evaluateBoth(t, expression, layout, row)
+
+				if tree.value != closure.value || (tree.err == nil) != (closure.err == nil) {
+					t.Errorf("%s %v %v: EvaluateE returned %v, %v but the compiled expression returned %v, %v",
+						name, expression, row, tree.value, tree.err, closure.value, closure.err)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkCompiled(b *testing.B) {
+	layout := NewRowLayout([]string{"a", "b", "f", "s"}, Schema{"a": IntegerType, "b": IntegerType, "f": FloatType, "s": CharType})
+	expressions, rows := compiledExpressions(), compiledRows()[:2]
+
+	compiled := make([]CompiledExpression, len(expressions))
+	for i, expression := range expressions {
+		var err error
+		if compiled[i], err = Compile(expression, layout); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, expression := range compiled {
+			for _, row := range rows {
+				expression(row)
+			}
+		}
+	}
+}
+
+func BenchmarkEvaluateE(b *testing.B) {
+	layout := NewRowLayout([]string{"a", "b", "f", "s"}, nil)
+	expressions, rows := compiledExpressions(), compiledRows()[:2]
+
+	symbols := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		symbols[i] = layout.symbols(row)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, expression := range expressions {
+			for _, row := range symbols {
+				expression.EvaluateE(row)
+			}
+		}
+	}
+}
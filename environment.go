@@ -0,0 +1,49 @@
+package common
+
+//Environment holds what the evaluation of an expression depends on besides its symbols. Nil fields
+//leave the expression using DefaultFunctions, DefaultAggregates and the executor set by SetSubqueryExecutor.
+type Environment struct {
+	Functions  *FunctionRegistry
+	Aggregates *AggregateRegistry
+	Subqueries SubqueryExecutor
+}
+
+//Bind returns a copy of an expression tree whose function calls, aggregates and subqueries use the
+//registries and the subquery executor of the environment. The input tree is not modified.
+func Bind(expression Expression, environment Environment) Expression {
+	return Rewrite(expression, func(e Expression) Expression {
+		switch node := e.(type) {
+		case *FuncCallCommon:
+			bound := *node
+			if environment.Functions != nil {
+				bound.functions = environment.Functions
+			}
+			return &bound
+		case *AggregateCommon:
+			bound := *node
+			if environment.Aggregates != nil {
+				bound.aggregates = environment.Aggregates
+			}
+			return &bound
+		case *InSelectCommon:
+			bound := *node
+			if environment.Subqueries != nil {
+				bound.executor = environment.Subqueries
+			}
+			return &bound
+		case *ScalarSubqueryCommon:
+			bound := *node
+			if environment.Subqueries != nil {
+				bound.executor = environment.Subqueries
+			}
+			return &bound
+		case *ExistsCommon:
+			bound := *node
+			if environment.Subqueries != nil {
+				bound.executor = environment.Subqueries
+			}
+			return &bound
+		}
+		return e
+	})
+}
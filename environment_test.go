@@ -0,0 +1,115 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBindUsesEnvironment(t *testing.T) {
+	functions := NewFunctionRegistry()
+	if err := functions.Register(ScalarFunction{"REVERSE", 1, 1, true, true, stringFunction("REVERSE", func(s string) string {
+		runes := []rune(s)
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return string(runes)
+	}), returns(CharType)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := LookupScalarFunction("REVERSE"); ok {
+		t.Fatal("registering in a registry changed DefaultFunctions")
+	}
+
+	call := NewFuncCallCommon("reverse", []Expression{NewFuncCallCommon("upper", []Expression{NewIdCommon("a", "")})})
+	symbols := map[string]interface{}{"a": "abc"}
+
+	if _, err := call.EvaluateE(symbols); err == nil {
+		t.Error("expected an unknown function error without binding")
+	}
+
+	bound := Bind(call, Environment{Functions: functions})
+	if value, err := bound.EvaluateE(symbols); err != nil || value != "CBA" {
+		t.Errorf("expected CBA, got %v, %v", value, err)
+	}
+	if _, err := TypeCheck(bound, Schema{"a": CharType}); err != nil {
+		t.Errorf("unexpected type error %v", err)
+	}
+	if optimized := Optimize(Bind(NewFuncCallCommon("reverse", []Expression{NewStringCommon("ab")}), Environment{Functions: functions})); optimized.(*StringCommon).value != "ba" {
+		t.Errorf("expected the bound call to be folded, got %v", optimized)
+	}
+}
+
+func TestBindAggregates(t *testing.T) {
+	aggregates := NewAggregateRegistry()
+	if err := aggregates.Register("CONCAT", func() Aggregate { return &concatAggregate{} }); err != nil {
+		t.Fatal(err)
+	}
+
+	aggregate := Bind(NewAggregateCommon("concat", NewIdCommon("a", ""), false), Environment{Aggregates: aggregates}).(*AggregateCommon)
+	rows := NewSliceIterator([]map[string]interface{}{{"a": "x"}, {"a": "y"}})
+
+	grouped, err := GroupRows(rows, nil, []*AggregateCommon{aggregate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value := grouped[0][aggregate.Key()]; value != "xy" {
+		t.Errorf("expected xy, got %v", value)
+	}
+
+	if _, err := NewAggregate("CONCAT", false); err == nil {
+		t.Error("registering in a registry changed DefaultAggregates")
+	}
+}
+
+type concatAggregate struct {
+	builder strings.Builder
+}
+
+func (c *concatAggregate) Init() {
+	c.builder.Reset()
+}
+
+func (c *concatAggregate) Accumulate(value interface{}) error {
+	if s, ok := value.(string); ok {
+		c.builder.WriteString(s)
+	}
+	return nil
+}
+
+func (c *concatAggregate) Merge(other Aggregate) error {
+	return c.Accumulate(other.Result())
+}
+
+func (c *concatAggregate) Result() interface{} {
+	return c.builder.String()
+}
+
+func TestBindSubqueryExecutor(t *testing.T) {
+	query := NewSelectTableCommand("t", "", nil, nil, nil, nil, nil, nil, 0, false, 0, false)
+	executor := func(rows ...[]interface{}) SubqueryExecutor {
+		return func(*SelectTableCommand, map[string]interface{}) ([][]interface{}, error) {
+			return rows, nil
+		}
+	}
+
+	expression := NewAndCommon(NewExistsCommon(query, false), NewInSelectCommon(NewIdCommon("a", ""), query, false))
+	scalar := NewScalarSubqueryCommon(query)
+
+	first := Bind(expression, Environment{Subqueries: executor([]interface{}{int64(1)})})
+	second := Bind(expression, Environment{Subqueries: executor([]interface{}{int64(2)})})
+	symbols := map[string]interface{}{"a": int64(1)}
+
+	if value, err := first.EvaluateE(symbols); err != nil || value != true {
+		t.Errorf("expected true, got %v, %v", value, err)
+	}
+	if value, err := second.EvaluateE(symbols); err != nil || value != false {
+		t.Errorf("expected false, got %v, %v", value, err)
+	}
+	if value, err := Bind(scalar, Environment{Subqueries: executor()}).EvaluateE(symbols); err != nil || value != nil {
+		t.Errorf("expected NULL, got %v, %v", value, err)
+	}
+	if _, err := expression.EvaluateE(symbols); err != ErrNoSubqueryExecutor {
+		t.Errorf("expected the unbound expression to use the default executor, got %v", err)
+	}
+}
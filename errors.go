@@ -0,0 +1,290 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+//ErrDivisionByZero is returned when the right operand of a division evaluates to zero.
+var ErrDivisionByZero = errors.New("Division by zero")
+
+//UnknownIdentifierError is returned when an identifier is not present in the symbol table.
+type UnknownIdentifierError struct {
+	Identifier string
+}
+
+func (e UnknownIdentifierError) Error() string {
+	return fmt.Sprintf("Identifier `%s' does not exist", e.Identifier)
+}
+
+//IntegerOverflowError is returned when the result of a function doesn't fit in an integer.
+type IntegerOverflowError struct {
+	Function string
+}
+
+func (e IntegerOverflowError) Error() string {
+	return fmt.Sprintf("Integer overflow in %s", e.Function)
+}
+
+//OperatorTypeError is returned when an operator is applied to operands of unsupported types.
+type OperatorTypeError struct {
+	Operator string
+	Operands []reflect.Type
+}
+
+func newOperatorTypeError(operator string, operands ...interface{}) OperatorTypeError {
+	types := make([]reflect.Type, len(operands))
+	for i, operand := range operands {
+		types[i] = reflect.TypeOf(operand)
+	}
+	return OperatorTypeError{operator, types}
+}
+
+func (e OperatorTypeError) Error() string {
+	if len(e.Operands) == 1 {
+		return fmt.Sprintf("Undefined %s operator for type %v", e.Operator, e.Operands[0])
+	}
+
+	types := make([]string, len(e.Operands))
+	for i, operand := range e.Operands {
+		types[i] = fmt.Sprint(operand)
+	}
+	return fmt.Sprintf("Undefined %s operator for types %s", e.Operator, strings.Join(types, " and "))
+}
+
+//NotNullViolationError is returned when a NULL value is stored in a column that is not nullable.
+type NotNullViolationError struct {
+	Column string
+}
+
+func (e NotNullViolationError) Error() string {
+	return fmt.Sprintf("Column `%s' cannot be NULL", e.Column)
+}
+
+//LikePatternError is returned when a LIKE pattern cannot be compiled.
+type LikePatternError struct {
+	Pattern string
+	Reason  string
+}
+
+func (e LikePatternError) Error() string {
+	return fmt.Sprintf("Invalid LIKE pattern `%s': %s", e.Pattern, e.Reason)
+}
+
+//SubqueryColumnsError is returned when a subquery used as an expression does not return exactly one column.
+type SubqueryColumnsError struct {
+	Columns int
+}
+
+func (e SubqueryColumnsError) Error() string {
+	return fmt.Sprintf("Subquery returns %d columns, expected 1", e.Columns)
+}
+
+//UnknownFunctionError is returned when calling a function that has not been registered.
+type UnknownFunctionError struct {
+	Name string
+}
+
+func (e UnknownFunctionError) Error() string {
+	return fmt.Sprintf("Function `%s' does not exist", e.Name)
+}
+
+//DuplicateFunctionError is returned when registering a function whose name is already taken.
+type DuplicateFunctionError struct {
+	Name string
+}
+
+func (e DuplicateFunctionError) Error() string {
+	return fmt.Sprintf("Function `%s' is already registered", e.Name)
+}
+
+//FunctionArityError is returned when a function is called with an unsupported number of arguments.
+type FunctionArityError struct {
+	Name    string
+	MinArgs int
+	MaxArgs int
+	Args    int
+}
+
+func (e FunctionArityError) Error() string {
+	switch {
+	case e.MaxArgs == VariadicArgs:
+		return fmt.Sprintf("Function `%s' expects at least %d arguments, got %d", e.Name, e.MinArgs, e.Args)
+	case e.MinArgs == e.MaxArgs:
+		return fmt.Sprintf("Function `%s' expects %d arguments, got %d", e.Name, e.MinArgs, e.Args)
+	}
+	return fmt.Sprintf("Function `%s' expects between %d and %d arguments, got %d", e.Name, e.MinArgs, e.MaxArgs, e.Args)
+}
+
+//AggregateMergeError is returned when merging aggregates of different kinds.
+type AggregateMergeError struct {
+	Aggregate Aggregate
+	Other     Aggregate
+}
+
+func (e AggregateMergeError) Error() string {
+	return fmt.Sprintf("Cannot merge aggregate %T into %T", e.Other, e.Aggregate)
+}
+
+//NegativeRowCountError is returned when the LIMIT or OFFSET of a query is negative.
+type NegativeRowCountError struct {
+	Clause string
+	Count  int64
+}
+
+func (e NegativeRowCountError) Error() string {
+	return fmt.Sprintf("%s must not be negative, got %d", e.Clause, e.Count)
+}
+
+//UngroupedColumnError is returned when a grouped query references a column that is neither grouped nor aggregated.
+type UngroupedColumnError struct {
+	Column string
+}
+
+func (e UngroupedColumnError) Error() string {
+	return fmt.Sprintf("Column `%s' must appear in the GROUP BY clause or be used in an aggregate function", e.Column)
+}
+
+//SetColumnCountError is returned when the selects combined by a set operator return a different number of columns.
+type SetColumnCountError struct {
+	Operator SetOperator
+	Left     int
+	Right    int
+}
+
+func (e SetColumnCountError) Error() string {
+	return fmt.Sprintf("Each %s query must have the same number of columns, got %d and %d", e.Operator, e.Left, e.Right)
+}
+
+//ScalarSubqueryRowsError is returned when a subquery used as a value returns more than one row.
+type ScalarSubqueryRowsError struct {
+	Rows int
+}
+
+func (e ScalarSubqueryRowsError) Error() string {
+	return fmt.Sprintf("Subquery used as an expression returns %d rows, expected at most 1", e.Rows)
+}
+
+//UnsupportedValueError is returned when encoding a value, expression or command of a type that has no encoded representation.
+type UnsupportedValueError struct {
+	Value interface{}
+}
+
+func (e UnsupportedValueError) Error() string {
+	return fmt.Sprintf("Cannot encode value of type %T", e.Value)
+}
+
+//UnknownNodeTypeError is returned when decoding a node whose type discriminator is unknown.
+type UnknownNodeTypeError struct {
+	Type string
+}
+
+func (e UnknownNodeTypeError) Error() string {
+	return fmt.Sprintf("Unknown node type `%s'", e.Type)
+}
+
+//MissingOperandError is returned when decoding a node without one of its required operands.
+type MissingOperandError struct {
+	Type string
+}
+
+func (e MissingOperandError) Error() string {
+	return fmt.Sprintf("Node of type `%s' is missing an operand", e.Type)
+}
+
+//UnknownNodeTagError is returned when decoding binary data that contains an unknown node tag.
+type UnknownNodeTagError struct {
+	Tag uint64
+}
+
+func (e UnknownNodeTagError) Error() string {
+	return fmt.Sprintf("Unknown node tag %d", e.Tag)
+}
+
+//BinaryVersionError is returned when decoding binary data written with an unsupported version of the encoding.
+type BinaryVersionError struct {
+	Version uint64
+}
+
+func (e BinaryVersionError) Error() string {
+	return fmt.Sprintf("Unsupported binary encoding version %d", e.Version)
+}
+
+//MessageSizeError is returned when the data of a message is larger than MaxMessageSize.
+type MessageSizeError struct {
+	Size uint64
+}
+
+func (e MessageSizeError) Error() string {
+	return fmt.Sprintf("Message of %d bytes exceeds the maximum of %d bytes", e.Size, MaxMessageSize)
+}
+
+//UnknownMessageTypeError is returned when writing or reading a message of an unknown type.
+type UnknownMessageTypeError struct {
+	Type MessageType
+}
+
+func (e UnknownMessageTypeError) Error() string {
+	return fmt.Sprintf("Unknown message type %d", int(e.Type))
+}
+
+//UnknownTypeError is returned when decoding the name of an unknown type.
+type UnknownTypeError struct {
+	Name string
+}
+
+func (e UnknownTypeError) Error() string {
+	return fmt.Sprintf("Unknown type `%s'", e.Name)
+}
+
+//UnexpectedMessageError is returned when a message arrives at a point of the protocol where its type is not expected.
+type UnexpectedMessageError struct {
+	Type MessageType
+}
+
+func (e UnexpectedMessageError) Error() string {
+	return fmt.Sprintf("Unexpected %s message", e.Type)
+}
+
+//ServerError is returned to a client when the server reports an error, either in an error message or in the trailer of a result set.
+type ServerError struct {
+	Message string
+}
+
+func (e ServerError) Error() string {
+	return e.Message
+}
+
+//RowLengthError is returned when a row of a result set does not have one value per column.
+type RowLengthError struct {
+	Columns int
+	Values  int
+}
+
+func (e RowLengthError) Error() string {
+	return fmt.Sprintf("Row has %d values, expected %d", e.Values, e.Columns)
+}
+
+//ColumnCountError is returned when the metadata of a table does not have one type per column name.
+type ColumnCountError struct {
+	Table string
+	Names int
+	Types int
+}
+
+func (e ColumnCountError) Error() string {
+	return fmt.Sprintf("Table `%s' has %d column names and %d column types", e.Table, e.Names, e.Types)
+}
+
+//ColumnValueError is returned when a value of a row is not valid for the type of its column.
+type ColumnValueError struct {
+	Column string
+	Type   string
+	Value  interface{}
+}
+
+func (e ColumnValueError) Error() string {
+	return fmt.Sprintf("Invalid %s value %v for column `%s'", e.Type, e.Value, e.Column)
+}
@@ -2,11 +2,347 @@ package common
 
 import (
 	"fmt"
-	"reflect"
+	"math"
+	"regexp"
+	"strings"
+	"time"
 )
 
+//Expression is a node of an expression tree evaluated against a symbol table.
+//Evaluate panics on failure while EvaluateE reports the failure as an error.
+//Children returns the operands evaluated against the same symbol table.
 type Expression interface {
 	Evaluate(symbols map[string]interface{}) interface{}
+	EvaluateE(symbols map[string]interface{}) (interface{}, error)
+	Children() []Expression
+}
+
+func mustEvaluate(expression Expression, symbols map[string]interface{}) interface{} {
+	value, err := expression.EvaluateE(symbols)
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+func evaluateOperands(symbols map[string]interface{}, leftValue Expression, rightValue Expression) (interface{}, interface{}, error) {
+	left, err := leftValue.EvaluateE(symbols)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	right, err := rightValue.EvaluateE(symbols)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return left, right, nil
+}
+
+//arithmetic applies a numeric operator, propagating NULL operands as NULL.
+func arithmetic(operator string, left interface{}, right interface{}) (interface{}, error) {
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	switch l := left.(type) {
+	case int64:
+		switch r := right.(type) {
+		case int64:
+			return integerArithmetic(operator, l, r)
+		case float64:
+			return floatArithmetic(operator, float64(l), r)
+		}
+	case float64:
+		switch r := right.(type) {
+		case int64:
+			return floatArithmetic(operator, l, float64(r))
+		case float64:
+			return floatArithmetic(operator, l, r)
+		}
+	}
+
+	return nil, newOperatorTypeError(operator, left, right)
+}
+
+func integerArithmetic(operator string, l int64, r int64) (interface{}, error) {
+	switch operator {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, ErrDivisionByZero
+		}
+		return l / r, nil
+	}
+
+	return nil, newOperatorTypeError(operator, l, r)
+}
+
+func floatArithmetic(operator string, l float64, r float64) (interface{}, error) {
+	switch operator {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, ErrDivisionByZero
+		}
+		return l / r, nil
+	}
+
+	return nil, newOperatorTypeError(operator, l, r)
+}
+
+//compareValues returns -1, 0 or 1 depending on whether left is less than, equal to or greater than right.
+func compareValues(operator string, left interface{}, right interface{}) (int, error) {
+	switch l := left.(type) {
+	case int64:
+		switch r := right.(type) {
+		case int64:
+			return compareIntegers(l, r), nil
+		case float64:
+			return compareFloats(float64(l), r), nil
+		}
+	case float64:
+		switch r := right.(type) {
+		case int64:
+			return compareFloats(l, float64(r)), nil
+		case float64:
+			return compareFloats(l, r), nil
+		}
+	case string:
+		if r, ok := right.(string); ok {
+			return strings.Compare(l, r), nil
+		}
+	case time.Time:
+		if r, ok := right.(time.Time); ok {
+			return compareTimes(l, r), nil
+		}
+	case bool:
+		if r, ok := right.(bool); ok {
+			return compareBooleans(l, r), nil
+		}
+	}
+
+	return 0, newOperatorTypeError(operator, left, right)
+}
+
+//compareBooleans orders FALSE before TRUE.
+func compareBooleans(l bool, r bool) int {
+	switch {
+	case l == r:
+		return 0
+	case r:
+		return -1
+	}
+	return 1
+}
+
+func compareTimes(l time.Time, r time.Time) int {
+	switch {
+	case l.Before(r):
+		return -1
+	case l.After(r):
+		return 1
+	}
+	return 0
+}
+
+//comparison evaluates a comparison operator, yielding NULL (UNKNOWN) when either operand is NULL.
+func comparison(operator string, left interface{}, right interface{}, test func(int) bool) (interface{}, error) {
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	result, err := compareValues(operator, left, right)
+	if err != nil {
+		return nil, err
+	}
+	return test(result), nil
+}
+
+//equalValues compares two non-NULL values, promoting numeric operands and comparing datetimes
+//by instant like compareValues does.
+func equalValues(left interface{}, right interface{}) bool {
+	if isNumeric(left) && isNumeric(right) {
+		result, _ := compareValues("=", left, right)
+		return result == 0
+	}
+
+	if l, ok := left.(time.Time); ok {
+		r, ok := right.(time.Time)
+		return ok && l.Equal(r)
+	}
+	return left == right
+}
+
+func isNumeric(value interface{}) bool {
+	switch value.(type) {
+	case int64, float64:
+		return true
+	}
+	return false
+}
+
+//hashKey normalizes a non-NULL value so that values considered equal by equalValues
+//share the same map key.
+func hashKey(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+			return int64(v)
+		}
+	case time.Time:
+		return v.Round(0).UTC()
+	}
+	return value
+}
+
+//constantValue returns the value of a literal expression.
+func constantValue(expression Expression) (interface{}, bool) {
+	switch e := expression.(type) {
+	case *IntCommon:
+		return e.value, true
+	case *FloatCommon:
+		return e.value, true
+	case *StringCommon:
+		return e.value, true
+	case *BoolCommon:
+		return e.value, true
+	case *TrueCommon, *FalseCommon, *NullCommon:
+		return e.Evaluate(nil), true
+	case IntCommon, FloatCommon, StringCommon, BoolCommon, TrueCommon, FalseCommon, NullCommon:
+		return e.Evaluate(nil), true
+	}
+	return nil, false
+}
+
+//withChildren returns a copy of an expression whose children are replaced, in the order returned by Children.
+func withChildren(expression Expression, operands []Expression) Expression {
+	switch e := expression.(type) {
+	case *SumCommon:
+		return NewSumCommon(operands[1], operands[0])
+	case *SubCommon:
+		return NewSubCommon(operands[1], operands[0])
+	case *MultCommon:
+		return NewMultCommon(operands[1], operands[0])
+	case *DivCommon:
+		return NewDivCommon(operands[1], operands[0])
+	case *EqCommon:
+		return NewEqCommon(operands[1], operands[0])
+	case *NeCommon:
+		return NewNeCommon(operands[1], operands[0])
+	case *LtCommon:
+		return NewLtCommon(operands[1], operands[0])
+	case *GtCommon:
+		return NewGtCommon(operands[1], operands[0])
+	case *LteCommon:
+		return NewLteCommon(operands[1], operands[0])
+	case *GteCommon:
+		return NewGteCommon(operands[1], operands[0])
+	case *LikeCommon:
+		return NewLikeEscapeCommon(operands[1], operands[0], e.escape, e.caseInsensitive)
+	case *AndCommon:
+		return NewAndCommon(operands[1], operands[0])
+	case *OrCommon:
+		return NewOrCommon(operands[1], operands[0])
+	case *NotCommon:
+		return NewNotCommon(operands[0])
+	case *BetweenCommon:
+		return NewBetweenCommon(operands[0], operands[1], operands[2], e.not, e.symmetric)
+	case *InCommon:
+		return NewInCommon(operands[0], operands[1:], e.not)
+	case *InSelectCommon:
+		in := NewInSelectCommon(operands[0], e.query, e.not)
+		in.executor = e.executor
+		return in
+	case *IsNullCommon:
+		return NewIsNullCommon(operands[0], e.not)
+	case *IsBoolCommon:
+		return NewIsBoolCommon(operands[0], e.truth, e.not)
+	case *IsDistinctFromCommon:
+		return NewIsDistinctFromCommon(operands[1], operands[0], e.not)
+	case *CaseCommon:
+		var operand, elseValue Expression
+		if e.operand != nil {
+			operand, operands = operands[0], operands[1:]
+		}
+		whens := make([]*WhenCommon, len(e.whens))
+		for i := range e.whens {
+			whens[i] = NewWhenCommon(operands[2*i], operands[2*i+1])
+		}
+		if e.elseValue != nil {
+			elseValue = operands[len(operands)-1]
+		}
+		return NewCaseCommon(operand, whens, elseValue)
+	case *FuncCallCommon:
+		call := NewFuncCallCommon(e.name, operands)
+		call.functions = e.functions
+		return call
+	case *AggregateCommon:
+		if e.argument != nil {
+			aggregate := NewAggregateCommon(e.name, operands[0], e.distinct)
+			aggregate.aggregates = e.aggregates
+			return aggregate
+		}
+	}
+	return expression
+}
+
+//toTruth converts an operand of a logical operator into a truth value, where NULL is UNKNOWN.
+func toTruth(operator string, value interface{}) (truth bool, known bool, err error) {
+	if value == nil {
+		return false, false, nil
+	}
+
+	if b, ok := value.(bool); ok {
+		return b, true, nil
+	}
+	return false, false, newOperatorTypeError(operator, value)
+}
+
+//EvaluatePredicate evaluates a WHERE, ON or HAVING condition. A missing condition
+//is satisfied by every row and an UNKNOWN result is treated as false.
+func EvaluatePredicate(expression Expression, symbols map[string]interface{}) (bool, error) {
+	if expression == nil {
+		return true, nil
+	}
+
+	value, err := expression.EvaluateE(symbols)
+	if err != nil {
+		return false, err
+	}
+
+	truth, _, err := toTruth("WHERE", value)
+	return truth, err
+}
+
+func compareIntegers(l int64, r int64) int {
+	switch {
+	case l < r:
+		return -1
+	case l > r:
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(l float64, r float64) int {
+	switch {
+	case l < r:
+		return -1
+	case l > r:
+		return 1
+	}
+	return 0
 }
 
 type IdCommon struct {
@@ -18,20 +354,30 @@ func NewIdCommon(tableName string, alias string) *IdCommon {
 	return &IdCommon{tableName, alias}
 }
 
-func (id IdCommon) Evaluate(symbols map[string]interface{}) interface{} {
-	var key string
-
+//Key returns the symbol the identifier is read from, prefixed as in `alias.column' when it is qualified.
+func (id IdCommon) Key() string {
 	if id.alias == "" {
-		key = id.name
-	} else {
-		key = fmt.Sprintf("%s.%s", id.name, id.alias)
+		return id.name
 	}
+	return fmt.Sprintf("%s.%s", id.name, id.alias)
+}
+
+func (id IdCommon) Evaluate(symbols map[string]interface{}) interface{} {
+	return mustEvaluate(id, symbols)
+}
+
+func (id IdCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	key := id.Key()
 
 	if value, ok := symbols[key]; ok {
-		return value
+		return value, nil
 	}
 
-	panic(fmt.Sprintf("Identifier `%s' does not exist", key))
+	return nil, UnknownIdentifierError{key}
+}
+
+func (id IdCommon) Children() []Expression {
+	return nil
 }
 
 type IntCommon struct {
@@ -42,6 +388,18 @@ func (i IntCommon) Evaluate(symbols map[string]interface{}) interface{} {
 	return i.value
 }
 
+func (i IntCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	return i.value, nil
+}
+
+func (i IntCommon) Children() []Expression {
+	return nil
+}
+
+func (i IntCommon) Value() int64 {
+	return i.value
+}
+
 func NewIntCommon(value int64) *IntCommon {
 	return &IntCommon{value}
 }
@@ -54,6 +412,18 @@ func (b BoolCommon) Evaluate(symbols map[string]interface{}) interface{} {
 	return b.value
 }
 
+func (b BoolCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	return b.value, nil
+}
+
+func (b BoolCommon) Children() []Expression {
+	return nil
+}
+
+func (b BoolCommon) Value() bool {
+	return b.value
+}
+
 func NewBoolCommon(value bool) *BoolCommon {
 	return &BoolCommon{value}
 }
@@ -66,6 +436,18 @@ func (f FloatCommon) Evaluate(symbols map[string]interface{}) interface{} {
 	return f.value
 }
 
+func (f FloatCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	return f.value, nil
+}
+
+func (f FloatCommon) Children() []Expression {
+	return nil
+}
+
+func (f FloatCommon) Value() float64 {
+	return f.value
+}
+
 func NewFloatCommon(value float64) *FloatCommon {
 	return &FloatCommon{value}
 }
@@ -78,6 +460,18 @@ func (s StringCommon) Evaluate(symbols map[string]interface{}) interface{} {
 	return s.value
 }
 
+func (s StringCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	return s.value, nil
+}
+
+func (s StringCommon) Children() []Expression {
+	return nil
+}
+
+func (s StringCommon) Value() string {
+	return s.value
+}
+
 func NewStringCommon(value string) *StringCommon {
 	return &StringCommon{value}
 }
@@ -97,31 +491,20 @@ type SumCommon struct {
 }
 
 func (s SumCommon) Evaluate(symbols map[string]interface{}) interface{} {
-	left := s.leftValue.Evaluate(symbols)
-	right := s.rightValue.Evaluate(symbols)
+	return mustEvaluate(s, symbols)
+}
 
-	switch l := left.(type) {
-	case int64:
-		switch r := right.(type) {
-		case int64:
-			return l + r
-		case float64:
-			return float64(l) + r
-		default:
-			panic(fmt.Sprintf("Undefined + operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
-		}
-	case float64:
-		switch r := right.(type) {
-		case int64:
-			return l + float64(r)
-		case float64:
-			return l + r
-		default:
-			panic(fmt.Sprintf("Undefined + operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
-		}
+func (s SumCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	left, right, err := evaluateOperands(symbols, s.leftValue, s.rightValue)
+	if err != nil {
+		return nil, err
 	}
 
-	panic(fmt.Sprintf("Undefined + operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
+	return arithmetic("+", left, right)
+}
+
+func (s SumCommon) Children() []Expression {
+	return []Expression{s.leftValue, s.rightValue}
 }
 
 func NewSumCommon(value Expression, expression Expression) *SumCommon {
@@ -134,31 +517,20 @@ type SubCommon struct {
 }
 
 func (s SubCommon) Evaluate(symbols map[string]interface{}) interface{} {
-	left := s.leftValue.Evaluate(symbols)
-	right := s.rightValue.Evaluate(symbols)
+	return mustEvaluate(s, symbols)
+}
 
-	switch l := left.(type) {
-	case int64:
-		switch r := right.(type) {
-		case int64:
-			return l - r
-		case float64:
-			return float64(l) - r
-		default:
-			panic(fmt.Sprintf("Undefined - operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
-		}
-	case float64:
-		switch r := right.(type) {
-		case int64:
-			return l - float64(r)
-		case float64:
-			return l - r
-		default:
-			panic(fmt.Sprintf("Undefined - operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
-		}
+func (s SubCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	left, right, err := evaluateOperands(symbols, s.leftValue, s.rightValue)
+	if err != nil {
+		return nil, err
 	}
 
-	panic(fmt.Sprintf("Undefined - operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
+	return arithmetic("-", left, right)
+}
+
+func (s SubCommon) Children() []Expression {
+	return []Expression{s.leftValue, s.rightValue}
 }
 
 func NewSubCommon(value Expression, expression Expression) *SubCommon {
@@ -171,31 +543,20 @@ type MultCommon struct {
 }
 
 func (m MultCommon) Evaluate(symbols map[string]interface{}) interface{} {
-	left := m.leftValue.Evaluate(symbols)
-	right := m.rightValue.Evaluate(symbols)
+	return mustEvaluate(m, symbols)
+}
 
-	switch l := left.(type) {
-	case int64:
-		switch r := right.(type) {
-		case int64:
-			return l * r
-		case float64:
-			return float64(l) * r
-		default:
-			panic(fmt.Sprintf("Undefined * operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
-		}
-	case float64:
-		switch r := right.(type) {
-		case int64:
-			return l * float64(r)
-		case float64:
-			return l * r
-		default:
-			panic(fmt.Sprintf("Undefined * operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
-		}
+func (m MultCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	left, right, err := evaluateOperands(symbols, m.leftValue, m.rightValue)
+	if err != nil {
+		return nil, err
 	}
 
-	panic(fmt.Sprintf("Undefined * operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
+	return arithmetic("*", left, right)
+}
+
+func (m MultCommon) Children() []Expression {
+	return []Expression{m.leftValue, m.rightValue}
 }
 
 func NewMultCommon(value Expression, expression Expression) *MultCommon {
@@ -212,31 +573,20 @@ func NewDivCommon(value Expression, expression Expression) *DivCommon {
 }
 
 func (d DivCommon) Evaluate(symbols map[string]interface{}) interface{} {
-	left := d.leftValue.Evaluate(symbols)
-	right := d.rightValue.Evaluate(symbols)
+	return mustEvaluate(d, symbols)
+}
 
-	switch l := left.(type) {
-	case int64:
-		switch r := right.(type) {
-		case int64:
-			return l / r
-		case float64:
-			return float64(l) / r
-		default:
-			panic(fmt.Sprintf("Undefined / operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
-		}
-	case float64:
-		switch r := right.(type) {
-		case int64:
-			return l / float64(r)
-		case float64:
-			return l / r
-		default:
-			panic(fmt.Sprintf("Undefined / operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
-		}
+func (d DivCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	left, right, err := evaluateOperands(symbols, d.leftValue, d.rightValue)
+	if err != nil {
+		return nil, err
 	}
 
-	panic(fmt.Sprintf("Undefined / operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
+	return arithmetic("/", left, right)
+}
+
+func (d DivCommon) Children() []Expression {
+	return []Expression{d.leftValue, d.rightValue}
 }
 
 type EqCommon struct {
@@ -245,7 +595,23 @@ type EqCommon struct {
 }
 
 func (e EqCommon) Evaluate(symbols map[string]interface{}) interface{} {
-	return e.leftValue.Evaluate(symbols) == e.rightValue.Evaluate(symbols)
+	return mustEvaluate(e, symbols)
+}
+
+func (e EqCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	left, right, err := evaluateOperands(symbols, e.leftValue, e.rightValue)
+	if err != nil {
+		return nil, err
+	}
+
+	if left == nil || right == nil {
+		return nil, nil
+	}
+	return equalValues(left, right), nil
+}
+
+func (e EqCommon) Children() []Expression {
+	return []Expression{e.leftValue, e.rightValue}
 }
 
 func NewEqCommon(value Expression, expression Expression) *EqCommon {
@@ -258,7 +624,23 @@ type NeCommon struct {
 }
 
 func (ne NeCommon) Evaluate(symbols map[string]interface{}) interface{} {
-	return ne.leftValue.Evaluate(symbols) != ne.rightValue.Evaluate(symbols)
+	return mustEvaluate(ne, symbols)
+}
+
+func (ne NeCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	left, right, err := evaluateOperands(symbols, ne.leftValue, ne.rightValue)
+	if err != nil {
+		return nil, err
+	}
+
+	if left == nil || right == nil {
+		return nil, nil
+	}
+	return !equalValues(left, right), nil
+}
+
+func (ne NeCommon) Children() []Expression {
+	return []Expression{ne.leftValue, ne.rightValue}
 }
 
 func NewNeCommon(value Expression, expression Expression) *NeCommon {
@@ -271,31 +653,20 @@ type LtCommon struct {
 }
 
 func (lt LtCommon) Evaluate(symbols map[string]interface{}) interface{} {
-	left := lt.leftValue.Evaluate(symbols)
-	right := lt.rightValue.Evaluate(symbols)
+	return mustEvaluate(lt, symbols)
+}
 
-	switch l := left.(type) {
-	case int64:
-		switch r := right.(type) {
-		case int64:
-			return l < r
-		case float64:
-			return float64(l) < r
-		default:
-			panic(fmt.Sprintf("Undefined < operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
-		}
-	case float64:
-		switch r := right.(type) {
-		case int64:
-			return l < float64(r)
-		case float64:
-			return l < r
-		default:
-			panic(fmt.Sprintf("Undefined < operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
-		}
+func (lt LtCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	left, right, err := evaluateOperands(symbols, lt.leftValue, lt.rightValue)
+	if err != nil {
+		return nil, err
 	}
 
-	panic(fmt.Sprintf("Undefined < operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
+	return comparison("<", left, right, func(result int) bool { return result < 0 })
+}
+
+func (lt LtCommon) Children() []Expression {
+	return []Expression{lt.leftValue, lt.rightValue}
 }
 
 func NewLtCommon(value Expression, expression Expression) *LtCommon {
@@ -308,31 +679,20 @@ type GtCommon struct {
 }
 
 func (gt GtCommon) Evaluate(symbols map[string]interface{}) interface{} {
-	left := gt.leftValue.Evaluate(symbols)
-	right := gt.rightValue.Evaluate(symbols)
+	return mustEvaluate(gt, symbols)
+}
 
-	switch l := left.(type) {
-	case int64:
-		switch r := right.(type) {
-		case int64:
-			return l > r
-		case float64:
-			return float64(l) > r
-		default:
-			panic(fmt.Sprintf("Undefined > operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
-		}
-	case float64:
-		switch r := right.(type) {
-		case int64:
-			return l > float64(r)
-		case float64:
-			return l > r
-		default:
-			panic(fmt.Sprintf("Undefined > operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
-		}
+func (gt GtCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	left, right, err := evaluateOperands(symbols, gt.leftValue, gt.rightValue)
+	if err != nil {
+		return nil, err
 	}
 
-	panic(fmt.Sprintf("Undefined > operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
+	return comparison(">", left, right, func(result int) bool { return result > 0 })
+}
+
+func (gt GtCommon) Children() []Expression {
+	return []Expression{gt.leftValue, gt.rightValue}
 }
 
 func NewGtCommon(value Expression, expression Expression) *GtCommon {
@@ -349,31 +709,20 @@ func NewLteCommon(value Expression, expression Expression) *LteCommon {
 }
 
 func (lte LteCommon) Evaluate(symbols map[string]interface{}) interface{} {
-	left := lte.leftValue.Evaluate(symbols)
-	right := lte.rightValue.Evaluate(symbols)
+	return mustEvaluate(lte, symbols)
+}
 
-	switch l := left.(type) {
-	case int64:
-		switch r := right.(type) {
-		case int64:
-			return l <= r
-		case float64:
-			return float64(l) <= r
-		default:
-			panic(fmt.Sprintf("Undefined <= operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
-		}
-	case float64:
-		switch r := right.(type) {
-		case int64:
-			return l <= float64(r)
-		case float64:
-			return l <= r
-		default:
-			panic(fmt.Sprintf("Undefined <= operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
-		}
+func (lte LteCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	left, right, err := evaluateOperands(symbols, lte.leftValue, lte.rightValue)
+	if err != nil {
+		return nil, err
 	}
 
-	panic(fmt.Sprintf("Undefined <= operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
+	return comparison("<=", left, right, func(result int) bool { return result <= 0 })
+}
+
+func (lte LteCommon) Children() []Expression {
+	return []Expression{lte.leftValue, lte.rightValue}
 }
 
 type GteCommon struct {
@@ -382,61 +731,283 @@ type GteCommon struct {
 }
 
 func (gte GteCommon) Evaluate(symbols map[string]interface{}) interface{} {
-	left := gte.leftValue.Evaluate(symbols)
-	right := gte.rightValue.Evaluate(symbols)
+	return mustEvaluate(gte, symbols)
+}
 
-	switch l := left.(type) {
-	case int64:
-		switch r := right.(type) {
-		case int64:
-			return l >= r
-		case float64:
-			return float64(l) >= r
-		default:
-			panic(fmt.Sprintf("Undefined >= operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
-		}
-	case float64:
-		switch r := right.(type) {
-		case int64:
-			return l >= float64(r)
-		case float64:
-			return l >= r
-		default:
-			panic(fmt.Sprintf("Undefined >= operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
-		}
+func (gte GteCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	left, right, err := evaluateOperands(symbols, gte.leftValue, gte.rightValue)
+	if err != nil {
+		return nil, err
 	}
 
-	panic(fmt.Sprintf("Undefined >= operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
+	return comparison(">=", left, right, func(result int) bool { return result >= 0 })
+}
+
+func (gte GteCommon) Children() []Expression {
+	return []Expression{gte.leftValue, gte.rightValue}
 }
 
 func NewGteCommon(value Expression, expression Expression) *GteCommon {
 	return &GteCommon{value, expression}
 }
 
+//BetweenCommon tests whether a value lies within an inclusive range. With symmetric set the
+//bounds are swapped when the lower bound is greater than the upper bound.
 type BetweenCommon struct {
-	rightValue Expression
-	leftValue  Expression
+	value     Expression
+	lower     Expression
+	upper     Expression
+	not       bool
+	symmetric bool
+}
+
+func (b BetweenCommon) operator() string {
+	if b.not {
+		return "NOT BETWEEN"
+	}
+	return "BETWEEN"
 }
 
 func (b BetweenCommon) Evaluate(symbols map[string]interface{}) interface{} {
-	panic("BETWEEN operator not implemented")
+	return mustEvaluate(b, symbols)
+}
+
+func (b BetweenCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	value, err := b.value.EvaluateE(symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	lower, upper, err := evaluateOperands(symbols, b.lower, b.upper)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.test(value, lower, upper)
+}
+
+//test returns whether a value lies between the evaluated bounds.
+func (b BetweenCommon) test(value interface{}, lower interface{}, upper interface{}) (interface{}, error) {
+	if b.symmetric && lower != nil && upper != nil {
+		result, err := compareValues(b.operator(), lower, upper)
+		if err != nil {
+			return nil, err
+		}
+		if result > 0 {
+			lower, upper = upper, lower
+		}
+	}
+
+	aboveLower, err := comparison(b.operator(), value, lower, func(result int) bool { return result >= 0 })
+	if err != nil {
+		return nil, err
+	}
+
+	belowUpper, err := comparison(b.operator(), value, upper, func(result int) bool { return result <= 0 })
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case aboveLower == false || belowUpper == false:
+		return b.not, nil
+	case aboveLower == nil || belowUpper == nil:
+		return nil, nil
+	}
+	return !b.not, nil
+}
+
+func (b BetweenCommon) Children() []Expression {
+	return []Expression{b.value, b.lower, b.upper}
 }
 
-func NewBetweenCommon(value Expression, expression Expression) *BetweenCommon {
-	return &BetweenCommon{value, expression}
+//NewBetweenCommon creates an instance of BetweenCommon for `value [NOT] BETWEEN [SYMMETRIC] lower AND upper'.
+func NewBetweenCommon(value Expression, lower Expression, upper Expression, not bool, symmetric bool) *BetweenCommon {
+	return &BetweenCommon{value, lower, upper, not, symmetric}
 }
 
+//LikeCommon matches a string against a LIKE pattern where `%' matches any sequence of
+//characters and `_' matches a single character.
 type LikeCommon struct {
-	rightValue Expression
-	leftValue  Expression
+	rightValue      Expression
+	leftValue       Expression
+	escape          rune
+	caseInsensitive bool
+	pattern         *regexp.Regexp
+	patternErr      error
+}
+
+func (l LikeCommon) operator() string {
+	if l.caseInsensitive {
+		return "ILIKE"
+	}
+	return "LIKE"
 }
 
 func (l LikeCommon) Evaluate(symbols map[string]interface{}) interface{} {
-	panic("LIKE operator not implemented")
+	return mustEvaluate(l, symbols)
+}
+
+func (l LikeCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	left, right, err := evaluateOperands(symbols, l.leftValue, l.rightValue)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.match(left, right)
+}
+
+//match matches an evaluated value against an evaluated pattern.
+func (l LikeCommon) match(left interface{}, right interface{}) (interface{}, error) {
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	value, ok1 := left.(string)
+	pattern, ok2 := right.(string)
+
+	if !(ok1 && ok2) {
+		return nil, newOperatorTypeError(l.operator(), left, right)
+	}
+
+	compiled, err := l.pattern, l.patternErr
+	if compiled == nil && err == nil {
+		compiled, err = compileLikePattern(pattern, l.escape, l.caseInsensitive)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return compiled.MatchString(value), nil
+}
+
+func (l LikeCommon) Children() []Expression {
+	return []Expression{l.leftValue, l.rightValue}
 }
 
 func NewLikeCommon(value Expression, expression Expression) *LikeCommon {
-	return &LikeCommon{value, expression}
+	return NewLikeEscapeCommon(value, expression, 0, false)
+}
+
+func NewILikeCommon(value Expression, expression Expression) *LikeCommon {
+	return NewLikeEscapeCommon(value, expression, 0, true)
+}
+
+//NewLikeEscapeCommon creates a LIKE, or ILIKE when caseInsensitive is set, with an ESCAPE character.
+//Constant patterns are compiled once here instead of on every evaluation.
+func NewLikeEscapeCommon(value Expression, expression Expression, escape rune, caseInsensitive bool) *LikeCommon {
+	like := &LikeCommon{rightValue: value, leftValue: expression, escape: escape, caseInsensitive: caseInsensitive}
+
+	if pattern, ok := constantString(value); ok {
+		like.pattern, like.patternErr = compileLikePattern(pattern, escape, caseInsensitive)
+	}
+
+	return like
+}
+
+//InCommon tests whether a value is equal to any expression of a list. Lists made only of
+//constants are turned into a hash set when the node is created.
+type InCommon struct {
+	value   Expression
+	list    []Expression
+	not     bool
+	set     map[interface{}]struct{}
+	hasNull bool
+}
+
+func (in InCommon) Evaluate(symbols map[string]interface{}) interface{} {
+	return mustEvaluate(in, symbols)
+}
+
+func (in InCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	value, err := in.value.EvaluateE(symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	if in.set != nil {
+		return in.lookup(value), nil
+	}
+
+	candidates := make([]interface{}, len(in.list))
+	for i, expression := range in.list {
+		if candidates[i], err = expression.EvaluateE(symbols); err != nil {
+			return nil, err
+		}
+	}
+
+	return inList(value, candidates, in.not), nil
+}
+
+func (in InCommon) Children() []Expression {
+	return append([]Expression{in.value}, in.list...)
+}
+
+//lookup evaluates the IN of a value against the hash set of a list made only of constants.
+func (in InCommon) lookup(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	if _, ok := in.set[hashKey(value)]; ok {
+		return !in.not
+	}
+	return inResult(in.not, in.hasNull)
+}
+
+//inList evaluates `value [NOT] IN candidates' following SQL semantics, where a NULL candidate
+//turns a failed match into UNKNOWN.
+func inList(value interface{}, candidates []interface{}, not bool) interface{} {
+	if len(candidates) == 0 {
+		return not
+	}
+
+	if value == nil {
+		return nil
+	}
+
+	hasNull := false
+	for _, candidate := range candidates {
+		if candidate == nil {
+			hasNull = true
+		} else if equalValues(value, candidate) {
+			return !not
+		}
+	}
+
+	return inResult(not, hasNull)
+}
+
+func inResult(not bool, hasNull bool) interface{} {
+	if hasNull {
+		return nil
+	}
+	return not
+}
+
+//NewInCommon creates an instance of InCommon for `value [NOT] IN (list)'.
+func NewInCommon(value Expression, list []Expression, not bool) *InCommon {
+	in := &InCommon{value: value, list: list, not: not}
+
+	if len(list) == 0 {
+		return in
+	}
+
+	set := map[interface{}]struct{}{}
+	for _, expression := range list {
+		constant, ok := constantValue(expression)
+		if !ok {
+			return in
+		}
+
+		if constant == nil {
+			in.hasNull = true
+		} else {
+			set[hashKey(constant)] = struct{}{}
+		}
+	}
+
+	in.set = set
+	return in
 }
 
 type NotCommon struct {
@@ -444,31 +1015,82 @@ type NotCommon struct {
 }
 
 func (n NotCommon) Evaluate(symbols map[string]interface{}) interface{} {
-	operand := n.not.Evaluate(symbols)
-	if b, ok := operand.(bool); ok {
-		return !b
+	return mustEvaluate(n, symbols)
+}
+
+func (n NotCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	operand, err := n.not.EvaluateE(symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	truth, known, err := toTruth("NOT", operand)
+	if err != nil || !known {
+		return nil, err
 	}
-	panic(fmt.Sprintf("Undefined NOT operator for type %d", reflect.TypeOf(operand)))
+	return !truth, nil
+}
+
+func (n NotCommon) Children() []Expression {
+	return []Expression{n.not}
 }
 
 func NewNotCommon(value Expression) *NotCommon {
 	return &NotCommon{value}
 }
 
+//evaluateLogical evaluates AND and OR, where dominant is the operand value deciding the result on its own.
+//The right operand is not evaluated when the left one is dominant, so `FALSE AND 1 / 0 = 1' is false.
+func evaluateLogical(operator string, dominant bool, left Expression, right Expression, symbols map[string]interface{}) (interface{}, error) {
+	a, err := left.EvaluateE(symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	aTruth, aKnown, err := toTruth(operator, a)
+	if err != nil {
+		return nil, err
+	}
+	if aKnown && aTruth == dominant {
+		return dominant, nil
+	}
+
+	b, err := right.EvaluateE(symbols)
+	if err != nil {
+		return nil, err
+	}
+	return logicalResult(operator, dominant, aKnown, b)
+}
+
+//logicalResult combines the right operand of AND or OR with a left operand that isn't dominant.
+func logicalResult(operator string, dominant bool, leftKnown bool, right interface{}) (interface{}, error) {
+	truth, known, err := toTruth(operator, right)
+	switch {
+	case err != nil:
+		return nil, err
+	case known && truth == dominant:
+		return dominant, nil
+	case leftKnown && known:
+		return !dominant, nil
+	}
+	return nil, nil
+}
+
 type AndCommon struct {
 	rightValue Expression
 	leftValue  Expression
 }
 
 func (a AndCommon) Evaluate(symbols map[string]interface{}) interface{} {
-	left, ok1 := a.leftValue.Evaluate(symbols).(bool)
-	right, ok2 := a.rightValue.Evaluate(symbols).(bool)
+	return mustEvaluate(a, symbols)
+}
 
-	if !(ok1 && ok2) {
-		panic(fmt.Sprintf("Undefined AND operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
-	}
+func (a AndCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	return evaluateLogical("AND", false, a.leftValue, a.rightValue, symbols)
+}
 
-	return left && right
+func (a AndCommon) Children() []Expression {
+	return []Expression{a.leftValue, a.rightValue}
 }
 
 func NewAndCommon(value Expression, expression Expression) *AndCommon {
@@ -481,20 +1103,266 @@ type OrCommon struct {
 }
 
 func (o OrCommon) Evaluate(symbols map[string]interface{}) interface{} {
-	left, ok1 := o.leftValue.Evaluate(symbols).(bool)
-	right, ok2 := o.rightValue.Evaluate(symbols).(bool)
+	return mustEvaluate(o, symbols)
+}
 
-	if !(ok1 && ok2) {
-		panic(fmt.Sprintf("Undefined OR operator for types %v and %v", reflect.TypeOf(left), reflect.TypeOf(right)))
-	}
+func (o OrCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	return evaluateLogical("OR", true, o.leftValue, o.rightValue, symbols)
+}
 
-	return left || right
+func (o OrCommon) Children() []Expression {
+	return []Expression{o.leftValue, o.rightValue}
 }
 
 func NewOrCommon(value Expression, expression Expression) *OrCommon {
 	return &OrCommon{value, expression}
 }
 
+//IsNullCommon tests whether a value is NULL. It never evaluates to UNKNOWN.
+type IsNullCommon struct {
+	value Expression
+	not   bool
+}
+
+func (i IsNullCommon) Evaluate(symbols map[string]interface{}) interface{} {
+	return mustEvaluate(i, symbols)
+}
+
+func (i IsNullCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	value, err := i.value.EvaluateE(symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	return (value == nil) != i.not, nil
+}
+
+func (i IsNullCommon) Children() []Expression {
+	return []Expression{i.value}
+}
+
+//NewIsNullCommon creates an instance of IsNullCommon for `value IS [NOT] NULL'.
+func NewIsNullCommon(value Expression, not bool) *IsNullCommon {
+	return &IsNullCommon{value, not}
+}
+
+//IsBoolCommon tests whether a boolean value is TRUE or FALSE, where NULL is neither.
+type IsBoolCommon struct {
+	value Expression
+	truth bool
+	not   bool
+}
+
+func (i IsBoolCommon) Evaluate(symbols map[string]interface{}) interface{} {
+	return mustEvaluate(i, symbols)
+}
+
+func (i IsBoolCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	value, err := i.value.EvaluateE(symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	return i.test(value)
+}
+
+func (i IsBoolCommon) test(value interface{}) (interface{}, error) {
+	truth, known, err := toTruth("IS", value)
+	if err != nil {
+		return nil, err
+	}
+
+	return (known && truth == i.truth) != i.not, nil
+}
+
+func (i IsBoolCommon) Children() []Expression {
+	return []Expression{i.value}
+}
+
+//NewIsBoolCommon creates an instance of IsBoolCommon for `value IS [NOT] TRUE' or `value IS [NOT] FALSE'.
+func NewIsBoolCommon(value Expression, truth bool, not bool) *IsBoolCommon {
+	return &IsBoolCommon{value, truth, not}
+}
+
+//IsDistinctFromCommon is a NULL safe comparison: two NULL values are not distinct, and a NULL value
+//is distinct from any other value.
+type IsDistinctFromCommon struct {
+	rightValue Expression
+	leftValue  Expression
+	not        bool
+}
+
+func (i IsDistinctFromCommon) Evaluate(symbols map[string]interface{}) interface{} {
+	return mustEvaluate(i, symbols)
+}
+
+func (i IsDistinctFromCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	left, right, err := evaluateOperands(symbols, i.leftValue, i.rightValue)
+	if err != nil {
+		return nil, err
+	}
+
+	return i.test(left, right), nil
+}
+
+func (i IsDistinctFromCommon) test(left interface{}, right interface{}) interface{} {
+	var distinct bool
+	switch {
+	case left == nil || right == nil:
+		distinct = left != nil || right != nil
+	default:
+		distinct = !equalValues(left, right)
+	}
+
+	return distinct != i.not
+}
+
+func (i IsDistinctFromCommon) Children() []Expression {
+	return []Expression{i.leftValue, i.rightValue}
+}
+
+//NewIsDistinctFromCommon creates an instance of IsDistinctFromCommon for `expression IS [NOT] DISTINCT FROM value'.
+func NewIsDistinctFromCommon(value Expression, expression Expression, not bool) *IsDistinctFromCommon {
+	return &IsDistinctFromCommon{value, expression, not}
+}
+
+//WhenCommon is a WHEN ... THEN ... branch of a CASE expression.
+type WhenCommon struct {
+	condition Expression
+	result    Expression
+}
+
+func NewWhenCommon(condition Expression, result Expression) *WhenCommon {
+	return &WhenCommon{condition, result}
+}
+
+//CaseCommon represents both searched and simple CASE expressions. A simple CASE has an operand
+//which is compared against each WHEN value, while a searched CASE evaluates each WHEN as a
+//condition. When a branch always evaluates to float64, integer results are promoted to float64
+//like SumCommon promotes its operands, so that the type of the result doesn't depend on the branch
+//taken. Branches are typed from their constants, so a branch reading a column never promotes the others.
+type CaseCommon struct {
+	operand     Expression
+	whens       []*WhenCommon
+	elseValue   Expression
+	floatResult bool
+}
+
+func (c CaseCommon) Evaluate(symbols map[string]interface{}) interface{} {
+	return mustEvaluate(c, symbols)
+}
+
+func (c CaseCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	var operand interface{}
+	if c.operand != nil {
+		var err error
+		if operand, err = c.operand.EvaluateE(symbols); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, when := range c.whens {
+		matched, err := c.matches(operand, when, symbols)
+		if err != nil {
+			return nil, err
+		}
+
+		if matched {
+			return c.unify(when.result.EvaluateE(symbols))
+		}
+	}
+
+	if c.elseValue == nil {
+		return nil, nil
+	}
+	return c.unify(c.elseValue.EvaluateE(symbols))
+}
+
+func (c CaseCommon) Children() []Expression {
+	children := []Expression{}
+	if c.operand != nil {
+		children = append(children, c.operand)
+	}
+	for _, when := range c.whens {
+		children = append(children, when.condition, when.result)
+	}
+	if c.elseValue != nil {
+		children = append(children, c.elseValue)
+	}
+	return children
+}
+
+func (c CaseCommon) matches(operand interface{}, when *WhenCommon, symbols map[string]interface{}) (bool, error) {
+	if c.operand == nil {
+		return EvaluatePredicate(when.condition, symbols)
+	}
+
+	value, err := when.condition.EvaluateE(symbols)
+	if err != nil {
+		return false, err
+	}
+	return matchesOperand(operand, value), nil
+}
+
+//matchesOperand returns whether the operand of a simple CASE equals the value of a WHEN.
+func matchesOperand(operand interface{}, value interface{}) bool {
+	return operand != nil && value != nil && equalValues(operand, value)
+}
+
+//unify promotes an integer result to float64 when another branch always evaluates to float64.
+func (c CaseCommon) unify(value interface{}, err error) (interface{}, error) {
+	if i, ok := value.(int64); ok && c.floatResult {
+		return float64(i), err
+	}
+	return value, err
+}
+
+//results returns the expressions of the branches of the CASE.
+func (c CaseCommon) results() []Expression {
+	results := []Expression{}
+	for _, when := range c.whens {
+		results = append(results, when.result)
+	}
+	if c.elseValue != nil {
+		results = append(results, c.elseValue)
+	}
+	return results
+}
+
+//alwaysFloat returns whether an expression evaluates to float64 whenever it isn't NULL, judging only from
+//its constants: a float constant, arithmetic with such an operand or a CASE whose results are promoted.
+func alwaysFloat(expression Expression) bool {
+	if value, ok := constantValue(expression); ok {
+		_, float := value.(float64)
+		return float
+	}
+
+	switch e := expression.(type) {
+	case *SumCommon:
+		return alwaysFloat(e.leftValue) || alwaysFloat(e.rightValue)
+	case *SubCommon:
+		return alwaysFloat(e.leftValue) || alwaysFloat(e.rightValue)
+	case *MultCommon:
+		return alwaysFloat(e.leftValue) || alwaysFloat(e.rightValue)
+	case *DivCommon:
+		return alwaysFloat(e.leftValue) || alwaysFloat(e.rightValue)
+	case *CaseCommon:
+		return e.floatResult
+	}
+	return false
+}
+
+//NewCaseCommon creates an instance of CaseCommon. The operand is nil for a searched CASE and
+//a nil elseValue makes the expression default to NULL.
+func NewCaseCommon(operand Expression, whens []*WhenCommon, elseValue Expression) *CaseCommon {
+	c := &CaseCommon{operand: operand, whens: whens, elseValue: elseValue}
+
+	for _, result := range c.results() {
+		c.floatResult = c.floatResult || alwaysFloat(result)
+	}
+	return c
+}
+
 type NullCommon struct {
 }
 
@@ -502,6 +1370,14 @@ func (n NullCommon) Evaluate(symbols map[string]interface{}) interface{} {
 	return nil
 }
 
+func (n NullCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+func (n NullCommon) Children() []Expression {
+	return nil
+}
+
 func NewNullCommon() *NullCommon {
 	return &NullCommon{}
 }
@@ -513,6 +1389,14 @@ func (f FalseCommon) Evaluate(symbols map[string]interface{}) interface{} {
 	return false
 }
 
+func (f FalseCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	return false, nil
+}
+
+func (f FalseCommon) Children() []Expression {
+	return nil
+}
+
 func NewFalseCommon() *FalseCommon {
 	return &FalseCommon{}
 }
@@ -524,6 +1408,14 @@ func (t TrueCommon) Evaluate(symbols map[string]interface{}) interface{} {
 	return true
 }
 
+func (t TrueCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	return true, nil
+}
+
+func (t TrueCommon) Children() []Expression {
+	return nil
+}
+
 func NewTrueCommon() *TrueCommon {
 	return &TrueCommon{}
 }
@@ -0,0 +1,436 @@
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEvaluateEReportsErrors(t *testing.T) {
+	one, zero := NewIntCommon(1), NewIntCommon(0)
+	missing := NewIdCommon("missing", "")
+
+	tests := []struct {
+		name       string
+		expression Expression
+		err        error
+	}{
+		{"unknown identifier", missing, UnknownIdentifierError{"missing"}},
+		{"unknown qualified identifier", NewIdCommon("t", "c"), UnknownIdentifierError{"t.c"}},
+		{"integer division by zero", NewDivCommon(zero, one), ErrDivisionByZero},
+		{"float division by zero", NewDivCommon(NewFloatCommon(0), NewFloatCommon(1)), ErrDivisionByZero},
+		{"operand types", NewSumCommon(one, NewStringCommon("a")), OperatorTypeError{"+", []reflect.Type{reflect.TypeOf(""), reflect.TypeOf(int64(0))}}},
+		{"comparison types", NewLtCommon(one, NewBoolCommon(true)), OperatorTypeError{"<", []reflect.Type{reflect.TypeOf(true), reflect.TypeOf(int64(0))}}},
+		{"nested operand", NewMultCommon(NewSumCommon(missing, one), one), UnknownIdentifierError{"missing"}},
+		{"logical operand", NewNotCommon(one), OperatorTypeError{"NOT", []reflect.Type{reflect.TypeOf(int64(0))}}},
+	}
+
+	for _, test := range tests {
+		value, err := test.expression.EvaluateE(map[string]interface{}{})
+		if !reflect.DeepEqual(err, test.err) {
+			t.Errorf("%s: expected error %v, got %v (%v)", test.name, test.err, err, value)
+		}
+	}
+}
+
+func TestEvaluatePanicsWithEvaluateEError(t *testing.T) {
+	defer func() {
+		if recovered := recover(); recovered != ErrDivisionByZero {
+			t.Errorf("expected a panic with %v, got %v", ErrDivisionByZero, recovered)
+		}
+	}()
+
+	NewDivCommon(NewIntCommon(0), NewIntCommon(1)).Evaluate(nil)
+}
+
+func TestArithmeticPromotesIntegers(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression Expression
+		expected   interface{}
+	}{
+		{"integer sum", NewSumCommon(NewIntCommon(2), NewIntCommon(3)), int64(5)},
+		{"integer difference", NewSubCommon(NewIntCommon(2), NewIntCommon(3)), int64(1)},
+		{"integer division", NewDivCommon(NewIntCommon(2), NewIntCommon(7)), int64(3)},
+		{"mixed product", NewMultCommon(NewFloatCommon(0.5), NewIntCommon(3)), 1.5},
+		{"mixed division", NewDivCommon(NewIntCommon(2), NewFloatCommon(7)), 3.5},
+	}
+
+	for _, test := range tests {
+		value, err := test.expression.EvaluateE(nil)
+		if err != nil || value != test.expected {
+			t.Errorf("%s: expected %v (%T), got %v (%T, %v)", test.name, test.expected, test.expected, value, value, err)
+		}
+	}
+}
+
+func TestDatetimeEqualityComparesInstants(t *testing.T) {
+	utc := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	local := utc.In(time.FixedZone("UTC-6", -6*60*60))
+	symbols := map[string]interface{}{"a": utc, "b": local}
+
+	a, b := NewIdCommon("a", ""), NewIdCommon("b", "")
+
+	tests := []struct {
+		name       string
+		expression Expression
+		expected   interface{}
+	}{
+		{"eq", NewEqCommon(b, a), true},
+		{"ne", NewNeCommon(b, a), false},
+		{"in", NewInCommon(a, []Expression{b}, false), true},
+		{"not in", NewInCommon(a, []Expression{b}, true), false},
+		{"is distinct from", NewIsDistinctFromCommon(b, a, false), false},
+		{"is not distinct from", NewIsDistinctFromCommon(b, a, true), true},
+	}
+
+	for _, test := range tests {
+		value, err := test.expression.EvaluateE(symbols)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+
+		if value != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, value)
+		}
+	}
+}
+
+func TestCaseResultTypeDoesNotDependOnBranch(t *testing.T) {
+	whens := func() []*WhenCommon {
+		return []*WhenCommon{NewWhenCommon(NewIdCommon("x", ""), NewIntCommon(1))}
+	}
+	column := NewCaseCommon(nil, whens(), NewIdCommon("f", ""))
+	constant := NewCaseCommon(nil, whens(), NewFloatCommon(2.5))
+	folded := NewCaseCommon(nil, whens(), NewSumCommon(NewFloatCommon(1), NewFloatCommon(0.5)))
+	mixed := NewCaseCommon(nil, whens(), NewMultCommon(NewFloatCommon(0.5), NewIdCommon("i", "")))
+	nested := NewCaseCommon(nil, whens(), NewCaseCommon(nil, whens(), NewFloatCommon(0.5)))
+
+	tests := []struct {
+		name       string
+		expression Expression
+		symbols    map[string]interface{}
+		expected   interface{}
+	}{
+		{"column then", column, map[string]interface{}{"x": true, "f": 2.5}, int64(1)},
+		{"column then with NULL column", column, map[string]interface{}{"x": true, "f": nil}, int64(1)},
+		{"column else", column, map[string]interface{}{"x": false, "f": 2.5}, 2.5},
+		{"constant then", constant, map[string]interface{}{"x": true}, 1.0},
+		{"expression then", folded, map[string]interface{}{"x": true}, 1.0},
+		{"optimized then", Optimize(folded), map[string]interface{}{"x": true}, 1.0},
+		{"rewritten then", Rewrite(folded, func(e Expression) Expression { return e }), map[string]interface{}{"x": true}, 1.0},
+		{"arithmetic with a column then", mixed, map[string]interface{}{"x": true, "i": nil}, 1.0},
+		{"nested then", nested, map[string]interface{}{"x": true}, 1.0},
+		{"integer branches", NewCaseCommon(nil, whens(), NewIdCommon("i", "")), map[string]interface{}{"x": true, "i": int64(2)}, int64(1)},
+	}
+
+	for _, test := range tests {
+		value, err := test.expression.EvaluateE(test.symbols)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+
+		if value != test.expected {
+			t.Errorf("%s: expected %v (%T), got %v (%T)", test.name, test.expected, test.expected, value, value)
+		}
+	}
+}
+
+//truthLiteral returns a literal of a truth value, where nil is UNKNOWN.
+func truthLiteral(value interface{}) Expression {
+	if value == nil {
+		return NewNullCommon()
+	}
+	return NewBoolCommon(value.(bool))
+}
+
+func TestThreeValuedLogic(t *testing.T) {
+	tests := []struct {
+		left, right interface{}
+		and, or     interface{}
+	}{
+		{true, true, true, true},
+		{true, false, false, true},
+		{true, nil, nil, true},
+		{false, true, false, true},
+		{false, false, false, false},
+		{false, nil, false, nil},
+		{nil, true, nil, true},
+		{nil, false, false, nil},
+		{nil, nil, nil, nil},
+	}
+
+	for _, test := range tests {
+		left, right := truthLiteral(test.left), truthLiteral(test.right)
+
+		if value, err := NewAndCommon(right, left).EvaluateE(nil); err != nil || value != test.and {
+			t.Errorf("%v AND %v: expected %v, got %v (%v)", test.left, test.right, test.and, value, err)
+		}
+		if value, err := NewOrCommon(right, left).EvaluateE(nil); err != nil || value != test.or {
+			t.Errorf("%v OR %v: expected %v, got %v (%v)", test.left, test.right, test.or, value, err)
+		}
+	}
+
+	for operand, expected := range map[interface{}]interface{}{true: false, false: true, nil: nil} {
+		if value, err := NewNotCommon(truthLiteral(operand)).EvaluateE(nil); err != nil || value != expected {
+			t.Errorf("NOT %v: expected %v, got %v (%v)", operand, expected, value, err)
+		}
+	}
+}
+
+func TestNullOperandsPropagate(t *testing.T) {
+	null, one := NewNullCommon(), NewIntCommon(1)
+
+	tests := []struct {
+		name       string
+		expression Expression
+	}{
+		{"sum", NewSumCommon(null, one)},
+		{"division by NULL", NewDivCommon(null, one)},
+		{"eq", NewEqCommon(one, null)},
+		{"ne", NewNeCommon(null, one)},
+		{"lt", NewLtCommon(one, null)},
+		{"gt", NewGtCommon(null, one)},
+		{"lte", NewLteCommon(null, null)},
+		{"gte", NewGteCommon(one, null)},
+	}
+
+	for _, test := range tests {
+		if value, err := test.expression.EvaluateE(nil); err != nil || value != nil {
+			t.Errorf("%s: expected NULL, got %v (%v)", test.name, value, err)
+		}
+	}
+}
+
+func TestEvaluatePredicateTreatsUnknownAsFalse(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression Expression
+		expected   bool
+	}{
+		{"missing condition", nil, true},
+		{"true", NewTrueCommon(), true},
+		{"false", NewFalseCommon(), false},
+		{"unknown", NewEqCommon(NewIntCommon(1), NewNullCommon()), false},
+		{"not unknown", NewNotCommon(NewNullCommon()), false},
+	}
+
+	for _, test := range tests {
+		if truth, err := EvaluatePredicate(test.expression, nil); err != nil || truth != test.expected {
+			t.Errorf("%s: expected %v, got %v (%v)", test.name, test.expected, truth, err)
+		}
+	}
+
+	if _, err := EvaluatePredicate(NewIntCommon(1), nil); err == nil {
+		t.Error("expected an error for a non boolean condition")
+	}
+}
+
+func TestBetween(t *testing.T) {
+	value := func(v interface{}) Expression {
+		switch v := v.(type) {
+		case int64:
+			return NewIntCommon(v)
+		case float64:
+			return NewFloatCommon(v)
+		case string:
+			return NewStringCommon(v)
+		}
+		return NewNullCommon()
+	}
+
+	tests := []struct {
+		value, lower, upper interface{}
+		not, symmetric      bool
+		expected            interface{}
+	}{
+		{int64(5), int64(1), int64(10), false, false, true},
+		{int64(1), int64(1), int64(10), false, false, true},
+		{int64(10), int64(1), int64(10), false, false, true},
+		{int64(0), int64(1), int64(10), false, false, false},
+		{int64(11), int64(1), int64(10), false, false, false},
+		{int64(0), int64(1), int64(10), true, false, true},
+		{int64(5), int64(1), int64(10), true, false, false},
+		{int64(5), int64(10), int64(1), false, false, false},
+		{int64(5), int64(10), int64(1), false, true, true},
+		{int64(5), int64(10), int64(1), true, true, false},
+		{int64(10), int64(10), int64(1), false, true, true},
+		{2.5, int64(2), int64(3), false, false, true},
+		{"m", "a", "z", false, false, true},
+		{"m", "n", "z", false, false, false},
+		{nil, int64(1), int64(10), false, false, nil},
+		{nil, int64(1), int64(10), true, false, nil},
+		{int64(5), nil, int64(10), false, false, nil},
+		{int64(15), nil, int64(10), false, false, false},
+		{int64(15), nil, int64(10), true, false, true},
+		{int64(0), int64(1), nil, false, false, false},
+		{int64(5), int64(1), nil, false, true, nil},
+	}
+
+	for _, test := range tests {
+		between := NewBetweenCommon(value(test.value), value(test.lower), value(test.upper), test.not, test.symmetric)
+
+		result, err := between.EvaluateE(nil)
+		if err != nil || result != test.expected {
+			t.Errorf("%v %s (symmetric %v) %v AND %v: expected %v, got %v (%v)", test.value, between.operator(), test.symmetric, test.lower, test.upper, test.expected, result, err)
+		}
+	}
+
+	if _, err := NewBetweenCommon(NewIntCommon(1), NewStringCommon("a"), NewStringCommon("z"), false, false).EvaluateE(nil); err == nil {
+		t.Error("expected an error comparing an integer with strings")
+	}
+}
+
+func TestInList(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		list     []interface{}
+		expected interface{}
+		notIn    interface{}
+	}{
+		{"match", int64(2), []interface{}{int64(1), int64(2)}, true, false},
+		{"no match", int64(3), []interface{}{int64(1), int64(2)}, false, true},
+		{"numeric promotion", 2.0, []interface{}{int64(1), int64(2)}, true, false},
+		{"strings", "b", []interface{}{"a", "b"}, true, false},
+		{"match with NULL", int64(2), []interface{}{nil, int64(2)}, true, false},
+		{"no match with NULL", int64(3), []interface{}{int64(1), nil}, nil, nil},
+		{"NULL value", nil, []interface{}{int64(1)}, nil, nil},
+		{"NULL in NULL", nil, []interface{}{nil}, nil, nil},
+		{"empty list", int64(1), nil, false, true},
+		{"NULL in empty list", nil, nil, false, true},
+	}
+
+	for _, test := range tests {
+		constants := make([]Expression, len(test.list))
+		identifiers := make([]Expression, len(test.list))
+		symbols := map[string]interface{}{"value": test.value}
+		for i, value := range test.list {
+			constants[i] = constant(value)
+			identifiers[i] = NewIdCommon(fmt.Sprint("c", i), "")
+			symbols[fmt.Sprint("c", i)] = value
+		}
+
+		for kind, list := range map[string][]Expression{"constant": constants, "column": identifiers} {
+			for not, expected := range map[bool]interface{}{false: test.expected, true: test.notIn} {
+				value, err := NewInCommon(NewIdCommon("value", ""), list, not).EvaluateE(symbols)
+				if err != nil || value != expected {
+					t.Errorf("%s (%s list, NOT %v): expected %v, got %v (%v)", test.name, kind, not, expected, value, err)
+				}
+			}
+		}
+	}
+}
+
+func TestCaseBranches(t *testing.T) {
+	x := NewIdCommon("x", "")
+	searched := NewCaseCommon(nil, []*WhenCommon{
+		NewWhenCommon(NewLtCommon(NewIntCommon(0), x), NewStringCommon("negative")),
+		NewWhenCommon(NewEqCommon(NewIntCommon(0), x), NewStringCommon("zero")),
+	}, NewStringCommon("positive"))
+	simple := NewCaseCommon(x, []*WhenCommon{
+		NewWhenCommon(NewIntCommon(1), NewStringCommon("one")),
+		NewWhenCommon(NewNullCommon(), NewStringCommon("null")),
+		NewWhenCommon(NewIntCommon(1), NewStringCommon("first match only")),
+	}, nil)
+
+	tests := []struct {
+		name       string
+		expression Expression
+		x          interface{}
+		expected   interface{}
+	}{
+		{"searched first branch", searched, int64(-1), "negative"},
+		{"searched second branch", searched, int64(0), "zero"},
+		{"searched else", searched, int64(1), "positive"},
+		{"searched unknown condition", searched, nil, "positive"},
+		{"simple match", simple, int64(1), "one"},
+		{"simple numeric promotion", simple, 1.0, "one"},
+		{"simple without else", simple, int64(2), nil},
+		{"simple NULL operand", simple, nil, nil},
+	}
+
+	for _, test := range tests {
+		value, err := test.expression.EvaluateE(map[string]interface{}{"x": test.x})
+		if err != nil || value != test.expected {
+			t.Errorf("%s: expected %v, got %v (%v)", test.name, test.expected, value, err)
+		}
+	}
+}
+
+func TestCaseEvaluatesOnlyTheMatchingBranch(t *testing.T) {
+	expression := NewCaseCommon(nil, []*WhenCommon{
+		NewWhenCommon(NewTrueCommon(), NewIntCommon(1)),
+		NewWhenCommon(NewIdCommon("missing", ""), NewDivCommon(NewIntCommon(0), NewIntCommon(1))),
+	}, NewDivCommon(NewIntCommon(0), NewIntCommon(1)))
+
+	if value, err := expression.EvaluateE(nil); err != nil || value != int64(1) {
+		t.Errorf("expected 1, got %v (%v)", value, err)
+	}
+}
+
+func TestIsPredicates(t *testing.T) {
+	for _, operand := range []interface{}{true, false, nil} {
+		value := truthLiteral(operand)
+
+		tests := []struct {
+			name       string
+			expression Expression
+			expected   bool
+		}{
+			{"IS NULL", NewIsNullCommon(value, false), operand == nil},
+			{"IS NOT NULL", NewIsNullCommon(value, true), operand != nil},
+			{"IS TRUE", NewIsBoolCommon(value, true, false), operand == true},
+			{"IS NOT TRUE", NewIsBoolCommon(value, true, true), operand != true},
+			{"IS FALSE", NewIsBoolCommon(value, false, false), operand == false},
+			{"IS NOT FALSE", NewIsBoolCommon(value, false, true), operand != false},
+		}
+
+		for _, test := range tests {
+			if result, err := test.expression.EvaluateE(nil); err != nil || result != test.expected {
+				t.Errorf("%v %s: expected %v, got %v (%v)", operand, test.name, test.expected, result, err)
+			}
+		}
+	}
+
+	if value, err := NewIsNullCommon(NewIntCommon(0), false).EvaluateE(nil); err != nil || value != false {
+		t.Errorf("0 IS NULL: expected false, got %v (%v)", value, err)
+	}
+	if _, err := NewIsBoolCommon(NewIntCommon(1), true, false).EvaluateE(nil); err == nil {
+		t.Error("1 IS TRUE: expected an error for a non boolean value")
+	}
+}
+
+func TestIsDistinctFrom(t *testing.T) {
+	tests := []struct {
+		left, right interface{}
+		distinct    bool
+	}{
+		{int64(1), int64(1), false},
+		{int64(1), 1.0, false},
+		{int64(1), int64(2), true},
+		{"a", "a", false},
+		{"a", int64(1), true},
+		{int64(1), nil, true},
+		{nil, int64(1), true},
+		{nil, nil, false},
+	}
+
+	for _, test := range tests {
+		symbols := map[string]interface{}{"l": test.left, "r": test.right}
+		l, r := NewIdCommon("l", ""), NewIdCommon("r", "")
+
+		for not, expected := range map[bool]bool{false: test.distinct, true: !test.distinct} {
+			if value, err := NewIsDistinctFromCommon(r, l, not).EvaluateE(symbols); err != nil || value != expected {
+				t.Errorf("%v IS DISTINCT FROM %v (NOT %v): expected %v, got %v (%v)", test.left, test.right, not, expected, value, err)
+			}
+		}
+
+		if test.left != nil && test.right != nil && equalValues(test.left, test.right) == test.distinct {
+			t.Errorf("%v = %v: expected the opposite of IS DISTINCT FROM", test.left, test.right)
+		}
+	}
+}
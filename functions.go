@@ -0,0 +1,334 @@
+package common
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+//VariadicArgs is used as MaxArgs by functions without an upper bound on their arguments.
+const VariadicArgs = -1
+
+//ScalarFunction describes a function that can be called from a FuncCallCommon.
+type ScalarFunction struct {
+	Name          string
+	MinArgs       int
+	MaxArgs       int
+	Deterministic bool
+	NullOnNull    bool
+	Call          func(args []interface{}) (interface{}, error)
+	ReturnType    func(args []Type) Type
+}
+
+func (f ScalarFunction) acceptsArgs(count int) bool {
+	return count >= f.MinArgs && (f.MaxArgs == VariadicArgs || count <= f.MaxArgs)
+}
+
+//FunctionRegistry holds the scalar functions available to function calls. Function names are case insensitive.
+type FunctionRegistry struct {
+	mutex     sync.RWMutex
+	functions map[string]ScalarFunction
+}
+
+//NewFunctionRegistry creates a FunctionRegistry holding the built-in functions.
+func NewFunctionRegistry() *FunctionRegistry {
+	registry := &FunctionRegistry{functions: map[string]ScalarFunction{}}
+	for _, function := range builtinFunctions() {
+		if err := registry.Register(function); err != nil {
+			panic(err)
+		}
+	}
+	return registry
+}
+
+//Register makes a function available to the function calls using the registry.
+func (r *FunctionRegistry) Register(function ScalarFunction) error {
+	name := strings.ToUpper(function.Name)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.functions[name]; ok {
+		return DuplicateFunctionError{name}
+	}
+
+	function.Name = name
+	r.functions[name] = function
+	return nil
+}
+
+//Lookup returns the function of the registry with the given name.
+func (r *FunctionRegistry) Lookup(name string) (ScalarFunction, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	function, ok := r.functions[strings.ToUpper(name)]
+	return function, ok
+}
+
+//DefaultFunctions is the registry used by function calls that are not bound to another one with Bind.
+var DefaultFunctions = NewFunctionRegistry()
+
+//RegisterScalarFunction registers a function in DefaultFunctions.
+func RegisterScalarFunction(function ScalarFunction) error {
+	return DefaultFunctions.Register(function)
+}
+
+//LookupScalarFunction returns the function of DefaultFunctions with the given name.
+func LookupScalarFunction(name string) (ScalarFunction, bool) {
+	return DefaultFunctions.Lookup(name)
+}
+
+//FuncCallCommon represents a call to a scalar function of a FunctionRegistry, DefaultFunctions unless the call is bound to another one.
+type FuncCallCommon struct {
+	name      string
+	args      []Expression
+	functions *FunctionRegistry
+}
+
+func NewFuncCallCommon(name string, args []Expression) *FuncCallCommon {
+	return &FuncCallCommon{name: strings.ToUpper(name), args: args}
+}
+
+//lookup returns the called function from the registry of the call.
+func (f FuncCallCommon) lookup() (ScalarFunction, bool) {
+	if f.functions == nil {
+		return DefaultFunctions.Lookup(f.name)
+	}
+	return f.functions.Lookup(f.name)
+}
+
+//Deterministic returns true if the called function is known and always returns the same result for the same arguments.
+func (f FuncCallCommon) Deterministic() bool {
+	function, ok := f.lookup()
+	return ok && function.Deterministic
+}
+
+func (f FuncCallCommon) Evaluate(symbols map[string]interface{}) interface{} {
+	return mustEvaluate(f, symbols)
+}
+
+func (f FuncCallCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	function, err := f.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]interface{}, len(f.args))
+	for i, arg := range f.args {
+		value, err := arg.EvaluateE(symbols)
+		if err != nil {
+			return nil, err
+		}
+
+		if value == nil && function.NullOnNull {
+			return nil, nil
+		}
+		args[i] = value
+	}
+
+	return function.Call(args)
+}
+
+//resolve returns the function called, or the error EvaluateE returns when it can't be called with the arguments.
+func (f FuncCallCommon) resolve() (ScalarFunction, error) {
+	function, ok := f.lookup()
+	if !ok {
+		return function, UnknownFunctionError{f.name}
+	}
+
+	if !function.acceptsArgs(len(f.args)) {
+		return function, FunctionArityError{function.Name, function.MinArgs, function.MaxArgs, len(f.args)}
+	}
+	return function, nil
+}
+
+func (f FuncCallCommon) Children() []Expression {
+	return f.args
+}
+
+func builtinFunctions() []ScalarFunction {
+	return []ScalarFunction{
+		{"UPPER", 1, 1, true, true, stringFunction("UPPER", strings.ToUpper), returns(CharType)},
+		{"LOWER", 1, 1, true, true, stringFunction("LOWER", strings.ToLower), returns(CharType)},
+		{"LENGTH", 1, 1, true, true, length, returns(IntegerType)},
+		{"SUBSTR", 2, 3, true, true, substr, returns(CharType)},
+		{"TRIM", 1, 2, true, true, trim, returns(CharType)},
+		{"ABS", 1, 1, true, true, numericFunction("ABS", abs, math.Abs), firstArgType},
+		{"ROUND", 1, 2, true, true, round, firstArgType},
+		{"FLOOR", 1, 1, true, true, numericFunction("FLOOR", identity, math.Floor), firstArgType},
+		{"CEIL", 1, 1, true, true, numericFunction("CEIL", identity, math.Ceil), firstArgType},
+	}
+}
+
+func stringFunction(name string, fn func(string) string) func([]interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		if s, ok := args[0].(string); ok {
+			return fn(s), nil
+		}
+		return nil, newOperatorTypeError(name, args...)
+	}
+}
+
+func numericFunction(name string, integer func(int64) (int64, error), float func(float64) float64) func([]interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case int64:
+			result, err := integer(v)
+			if err != nil {
+				return nil, err
+			}
+			return result, nil
+		case float64:
+			return float(v), nil
+		}
+		return nil, newOperatorTypeError(name, args...)
+	}
+}
+
+func identity(i int64) (int64, error) {
+	return i, nil
+}
+
+func abs(i int64) (int64, error) {
+	switch {
+	case i == math.MinInt64:
+		return 0, IntegerOverflowError{"ABS"}
+	case i < 0:
+		return -i, nil
+	}
+	return i, nil
+}
+
+func length(args []interface{}) (interface{}, error) {
+	if s, ok := args[0].(string); ok {
+		return int64(utf8.RuneCountInString(s)), nil
+	}
+	return nil, newOperatorTypeError("LENGTH", args...)
+}
+
+//substr returns the substring starting at the 1-based position start, optionally limited to count characters.
+func substr(args []interface{}) (interface{}, error) {
+	s, ok1 := args[0].(string)
+	start, ok2 := args[1].(int64)
+	if !(ok1 && ok2) {
+		return nil, newOperatorTypeError("SUBSTR", args...)
+	}
+
+	runes := []rune(s)
+	end := int64(len(runes)) + 1
+	if len(args) == 3 {
+		count, ok := args[2].(int64)
+		if !ok || count < 0 {
+			return nil, newOperatorTypeError("SUBSTR", args...)
+		}
+		end = start + count
+	}
+
+	if start < 1 {
+		start = 1
+	}
+	if end > int64(len(runes))+1 {
+		end = int64(len(runes)) + 1
+	}
+	if start >= end {
+		return "", nil
+	}
+
+	return string(runes[start-1 : end-1]), nil
+}
+
+func trim(args []interface{}) (interface{}, error) {
+	s, ok := args[0].(string)
+	if !ok {
+		return nil, newOperatorTypeError("TRIM", args...)
+	}
+
+	if len(args) == 1 {
+		return strings.TrimSpace(s), nil
+	}
+
+	cutset, ok := args[1].(string)
+	if !ok {
+		return nil, newOperatorTypeError("TRIM", args...)
+	}
+	return strings.Trim(s, cutset), nil
+}
+
+//maxInt64Digits and maxFloat64Digits bound the digits of ROUND. Rounding to more digits leaves
+//the value unchanged, and rounding to fewer than their negation gives zero or, for an integer too
+//large to round down, overflows.
+const (
+	maxInt64Digits   = 18
+	maxFloat64Digits = 308
+)
+
+func round(args []interface{}) (interface{}, error) {
+	var digits int64
+	if len(args) == 2 {
+		var ok bool
+		if digits, ok = args[1].(int64); !ok {
+			return nil, newOperatorTypeError("ROUND", args...)
+		}
+	}
+
+	switch v := args[0].(type) {
+	case int64:
+		result, err := roundInteger(v, digits)
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	case float64:
+		switch {
+		case digits > maxFloat64Digits:
+			return v, nil
+		case digits < -maxFloat64Digits:
+			return 0.0, nil
+		}
+		scale := math.Pow10(int(digits))
+		if math.IsInf(v*scale, 0) {
+			return v, nil
+		}
+		return math.Round(v*scale) / scale, nil
+	}
+
+	return nil, newOperatorTypeError("ROUND", args...)
+}
+
+//roundInteger rounds an integer half away from zero to a number of digits, in integer arithmetic so that
+//values beyond the precision of float64 are kept exact.
+func roundInteger(v int64, digits int64) (int64, error) {
+	switch {
+	case digits >= 0:
+		return v, nil
+	case digits < -maxInt64Digits:
+		//Zero is the only multiple of 10^19 held by an int64, and values from 5*10^18 round away from it.
+		if digits == -maxInt64Digits-1 && (v >= 5e18 || v <= -5e18) {
+			return 0, IntegerOverflowError{"ROUND"}
+		}
+		return 0, nil
+	}
+
+	scale := int64(1)
+	for i := digits; i < 0; i++ {
+		scale *= 10
+	}
+
+	remainder := v % scale
+	rounded := v - remainder
+	switch {
+	case remainder >= scale/2:
+		if rounded > math.MaxInt64-scale {
+			return 0, IntegerOverflowError{"ROUND"}
+		}
+		return rounded + scale, nil
+	case remainder <= -scale/2:
+		if rounded < math.MinInt64+scale {
+			return 0, IntegerOverflowError{"ROUND"}
+		}
+		return rounded - scale, nil
+	}
+	return rounded, nil
+}
@@ -0,0 +1,193 @@
+package common
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRoundClampsDigits(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		digits   int64
+		expected interface{}
+	}{
+		{int64(1234), -2, int64(1200)},
+		{int64(5), -400, int64(0)},
+		{int64(4999999999999999999), -19, int64(0)},
+		{int64(-4999999999999999999), -19, int64(0)},
+		{int64(5), 400, int64(5)},
+		{2.345, 2, 2.35},
+		{2.5, -400, 0.0},
+		{2.5, 400, 2.5},
+		{1e300, 100, 1e300},
+	}
+
+	for _, test := range tests {
+		call := NewFuncCallCommon("ROUND", []Expression{constant(test.value), NewIntCommon(test.digits)})
+
+		value, err := call.EvaluateE(map[string]interface{}{})
+		if err != nil {
+			t.Errorf("ROUND(%v, %d): unexpected error %v", test.value, test.digits, err)
+			continue
+		}
+
+		if value != test.expected {
+			t.Errorf("ROUND(%v, %d): expected %v (%T), got %v (%T)", test.value, test.digits, test.expected, test.expected, value, value)
+		}
+	}
+}
+
+func TestIntegerRoundingIsExact(t *testing.T) {
+	tests := []struct {
+		value    int64
+		digits   int64
+		expected int64
+	}{
+		{1250, -2, 1300},
+		{1249, -2, 1200},
+		{-1250, -2, -1300},
+		{-1249, -2, -1200},
+		{9007199254740993, -1, 9007199254740990},
+		{9007199254740995, -1, 9007199254741000},
+		{math.MaxInt64, -2, 9223372036854775800},
+		{math.MinInt64, -2, -9223372036854775800},
+		{math.MaxInt64, 3, math.MaxInt64},
+	}
+
+	for _, test := range tests {
+		value, err := NewFuncCallCommon("ROUND", []Expression{NewIntCommon(test.value), NewIntCommon(test.digits)}).EvaluateE(nil)
+		if err != nil || value != test.expected {
+			t.Errorf("ROUND(%d, %d): expected %d, got %v (%v)", test.value, test.digits, test.expected, value, err)
+		}
+	}
+}
+
+func TestIntegerOverflow(t *testing.T) {
+	tests := []Expression{
+		NewFuncCallCommon("ROUND", []Expression{NewIntCommon(math.MaxInt64), NewIntCommon(-1)}),
+		NewFuncCallCommon("ROUND", []Expression{NewIntCommon(math.MinInt64), NewIntCommon(-1)}),
+		NewFuncCallCommon("ROUND", []Expression{NewIntCommon(math.MaxInt64), NewIntCommon(-19)}),
+		NewFuncCallCommon("ROUND", []Expression{NewIntCommon(5e18), NewIntCommon(-19)}),
+		NewFuncCallCommon("ROUND", []Expression{NewIntCommon(-5e18), NewIntCommon(-19)}),
+		NewFuncCallCommon("ABS", []Expression{NewIntCommon(math.MinInt64)}),
+	}
+
+	for _, test := range tests {
+		if value, err := test.EvaluateE(nil); err == nil {
+			t.Errorf("%v: expected an overflow, got %v", test, value)
+		} else if _, ok := err.(IntegerOverflowError); !ok {
+			t.Errorf("%v: expected IntegerOverflowError, got %v", test, err)
+		}
+	}
+
+	if value, err := NewFuncCallCommon("ABS", []Expression{NewIntCommon(math.MinInt64 + 1)}).EvaluateE(nil); err != nil || value != int64(math.MaxInt64) {
+		t.Errorf("expected %d, got %v (%v)", int64(math.MaxInt64), value, err)
+	}
+}
+
+func constant(value interface{}) Expression {
+	expression, _ := literal(value)
+	return expression
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	call := func(name string, args ...interface{}) Expression {
+		expressions := make([]Expression, len(args))
+		for i, arg := range args {
+			expressions[i] = constant(arg)
+		}
+		return NewFuncCallCommon(name, expressions)
+	}
+
+	tests := []struct {
+		call     Expression
+		expected interface{}
+	}{
+		{call("upper", "abc"), "ABC"},
+		{call("Lower", "ABC"), "abc"},
+		{call("LENGTH", "año"), int64(3)},
+		{call("SUBSTR", "modest", int64(2)), "odest"},
+		{call("SUBSTR", "modest", int64(2), int64(3)), "ode"},
+		{call("SUBSTR", "modest", int64(0), int64(2)), "m"},
+		{call("SUBSTR", "modest", int64(10)), ""},
+		{call("TRIM", "  a b  "), "a b"},
+		{call("TRIM", "xxaxx", "x"), "a"},
+		{call("ABS", int64(-3)), int64(3)},
+		{call("ABS", -2.5), 2.5},
+		{call("ROUND", 2.5), 3.0},
+		{call("FLOOR", -1.5), -2.0},
+		{call("CEIL", 1.25), 2.0},
+		{call("CEIL", int64(4)), int64(4)},
+		{call("UPPER", nil), nil},
+		{call("SUBSTR", "modest", nil), nil},
+	}
+
+	for _, test := range tests {
+		value, err := test.call.EvaluateE(nil)
+		if err != nil || value != test.expected {
+			t.Errorf("%v: expected %v (%T), got %v (%T, %v)", test.call, test.expected, test.expected, value, value, err)
+		}
+	}
+}
+
+func TestFunctionCallErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		call Expression
+		err  error
+	}{
+		{"unknown function", NewFuncCallCommon("nope", nil), UnknownFunctionError{"NOPE"}},
+		{"too few arguments", NewFuncCallCommon("substr", []Expression{NewStringCommon("a")}), FunctionArityError{"SUBSTR", 2, 3, 1}},
+		{"too many arguments", NewFuncCallCommon("upper", []Expression{NewStringCommon("a"), NewStringCommon("b")}), FunctionArityError{"UPPER", 1, 1, 2}},
+		{"argument error", NewFuncCallCommon("upper", []Expression{NewIdCommon("missing", "")}), UnknownIdentifierError{"missing"}},
+	}
+
+	for _, test := range tests {
+		if _, err := test.call.EvaluateE(nil); err != test.err {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+
+	if _, err := NewFuncCallCommon("length", []Expression{NewIntCommon(1)}).EvaluateE(nil); err == nil {
+		t.Error("expected an error calling LENGTH with an integer")
+	}
+}
+
+func TestFunctionRegistry(t *testing.T) {
+	functions := NewFunctionRegistry()
+
+	calls := 0
+	variadic := ScalarFunction{"coalesce", 1, VariadicArgs, true, false, func(args []interface{}) (interface{}, error) {
+		calls++
+		for _, arg := range args {
+			if arg != nil {
+				return arg, nil
+			}
+		}
+		return nil, nil
+	}, firstArgType}
+
+	if err := functions.Register(variadic); err != nil {
+		t.Fatal(err)
+	}
+	if err := functions.Register(variadic); err != (DuplicateFunctionError{"COALESCE"}) {
+		t.Errorf("expected a DuplicateFunctionError, got %v", err)
+	}
+	if err := RegisterScalarFunction(ScalarFunction{Name: "upper"}); err != (DuplicateFunctionError{"UPPER"}) {
+		t.Errorf("expected a DuplicateFunctionError, got %v", err)
+	}
+
+	if function, ok := functions.Lookup("Coalesce"); !ok || function.Name != "COALESCE" {
+		t.Errorf("expected COALESCE to be found by a case insensitive name, got %v", function.Name)
+	}
+
+	call := Bind(NewFuncCallCommon("coalesce", []Expression{NewNullCommon(), NewNullCommon(), NewIntCommon(3)}), Environment{Functions: functions})
+	if value, err := call.EvaluateE(nil); err != nil || value != int64(3) || calls != 1 {
+		t.Errorf("expected 3 from a single call, got %v (%v) after %d calls", value, err, calls)
+	}
+
+	call = Bind(NewFuncCallCommon("coalesce", nil), Environment{Functions: functions})
+	if _, err := call.EvaluateE(nil); err != (FunctionArityError{"COALESCE", 1, VariadicArgs, 0}) {
+		t.Errorf("expected a FunctionArityError, got %v", err)
+	}
+}
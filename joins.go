@@ -0,0 +1,298 @@
+package common
+
+import (
+	"errors"
+	"sort"
+)
+
+//ErrNotEquiJoin is returned by the hash and sort-merge joins when the join condition does not
+//compare a column of each side with `='.
+var ErrNotEquiJoin = errors.New("Join condition is not an equi-join")
+
+//JoinSource is one side of a join. Its rows are keyed by column name and every key is prefixed
+//with the qualifier, as in `alias.column', so the merged rows can be read by IdCommon. Rows that
+//already hold prefixed keys, such as the output of a previous join, use an empty qualifier.
+type JoinSource struct {
+	Qualifier string
+	Columns   []string
+	Rows      RowIterator
+}
+
+//NewJoinSource creates an instance of JoinSource. The columns are used to pad unmatched rows with NULL values.
+func NewJoinSource(qualifier string, columns []string, rows RowIterator) JoinSource {
+	return JoinSource{qualifier, columns, rows}
+}
+
+func (s JoinSource) collect() ([]map[string]interface{}, error) {
+	rows, err := CollectRows(s.Rows)
+	if err != nil {
+		return nil, err
+	}
+
+	qualified := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		qualified[i] = map[string]interface{}{}
+		for column, value := range row {
+			qualified[i][qualify(s.Qualifier, column)] = value
+		}
+	}
+	return qualified, nil
+}
+
+func (s JoinSource) keys() map[string]bool {
+	keys := map[string]bool{}
+	for _, column := range s.Columns {
+		keys[qualify(s.Qualifier, column)] = true
+	}
+	return keys
+}
+
+//Join joins two sources with a hash join when the condition allows it and with a nested loop join otherwise.
+func Join(left JoinSource, right JoinSource, join JoinSelect) (RowIterator, error) {
+	if _, _, ok := equiJoinKeys(left, right, join.Condition(left.Qualifier)); ok {
+		return HashJoin(left, right, join)
+	}
+	return NestedLoopJoin(left, right, join)
+}
+
+//NestedLoopJoin joins two sources by evaluating the join condition for every pair of rows.
+func NestedLoopJoin(left JoinSource, right JoinSource, join JoinSelect) (RowIterator, error) {
+	return joinRows(left, right, join, func(leftRows, rightRows []map[string]interface{}, candidate func(int, int) error) error {
+		for i := range leftRows {
+			for j := range rightRows {
+				if err := candidate(i, j); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+//HashJoin joins two sources by hashing the right rows on the column compared by an `=' of the join condition.
+//The rest of the condition is evaluated for the rows sharing the same key.
+func HashJoin(left JoinSource, right JoinSource, join JoinSelect) (RowIterator, error) {
+	leftKey, rightKey, ok := equiJoinKeys(left, right, join.Condition(left.Qualifier))
+	if !ok || join.kind == CrossJoin {
+		return nil, ErrNotEquiJoin
+	}
+
+	return joinRows(left, right, join, func(leftRows, rightRows []map[string]interface{}, candidate func(int, int) error) error {
+		buckets := map[interface{}][]int{}
+		for j, row := range rightRows {
+			if value := row[rightKey]; value != nil {
+				buckets[hashKey(value)] = append(buckets[hashKey(value)], j)
+			}
+		}
+
+		for i, row := range leftRows {
+			value := row[leftKey]
+			if value == nil {
+				continue
+			}
+
+			for _, j := range buckets[hashKey(value)] {
+				if err := candidate(i, j); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+//SortMergeJoin joins two sources by sorting both sides on the column compared by an `=' of the join
+//condition and merging the runs of equal keys. The rest of the condition is evaluated for every pair of a run.
+func SortMergeJoin(left JoinSource, right JoinSource, join JoinSelect) (RowIterator, error) {
+	leftKey, rightKey, ok := equiJoinKeys(left, right, join.Condition(left.Qualifier))
+	if !ok || join.kind == CrossJoin {
+		return nil, ErrNotEquiJoin
+	}
+
+	return joinRows(left, right, join, func(leftRows, rightRows []map[string]interface{}, candidate func(int, int) error) error {
+		leftOrder, err := sortedByKey(leftRows, leftKey)
+		if err != nil {
+			return err
+		}
+
+		rightOrder, err := sortedByKey(rightRows, rightKey)
+		if err != nil {
+			return err
+		}
+
+		i, j := 0, 0
+		for i < len(leftOrder) && j < len(rightOrder) {
+			result, err := compareValues("JOIN", leftRows[leftOrder[i]][leftKey], rightRows[rightOrder[j]][rightKey])
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case result < 0:
+				i++
+			case result > 0:
+				j++
+			default:
+				leftEnd := runEnd(leftRows, leftOrder, leftKey, i)
+				rightEnd := runEnd(rightRows, rightOrder, rightKey, j)
+
+				for _, l := range leftOrder[i:leftEnd] {
+					for _, r := range rightOrder[j:rightEnd] {
+						if err := candidate(l, r); err != nil {
+							return err
+						}
+					}
+				}
+
+				i, j = leftEnd, rightEnd
+			}
+		}
+		return nil
+	})
+}
+
+//sortedByKey returns the indexes of the rows with a non NULL key, ordered by that key.
+func sortedByKey(rows []map[string]interface{}, key string) ([]int, error) {
+	order := []int{}
+	for i, row := range rows {
+		if row[key] != nil {
+			order = append(order, i)
+		}
+	}
+
+	var err error
+	sort.SliceStable(order, func(a, b int) bool {
+		result, compareErr := compareValues("JOIN", rows[order[a]][key], rows[order[b]][key])
+		if compareErr != nil && err == nil {
+			err = compareErr
+		}
+		return result < 0
+	})
+
+	return order, err
+}
+
+//runEnd returns the end of the run of rows sharing the key of order[start].
+func runEnd(rows []map[string]interface{}, order []int, key string, start int) int {
+	end := start + 1
+	for end < len(order) && equalValues(rows[order[start]][key], rows[order[end]][key]) {
+		end++
+	}
+	return end
+}
+
+//equiJoinKeys looks for an `=' between a column of each side among the conjuncts of the condition.
+func equiJoinKeys(left JoinSource, right JoinSource, condition Expression) (string, string, bool) {
+	leftKeys, rightKeys := left.keys(), right.keys()
+
+	for _, conjunct := range conjuncts(condition) {
+		eq, ok := conjunct.(*EqCommon)
+		if !ok {
+			continue
+		}
+
+		a, ok1 := eq.leftValue.(*IdCommon)
+		b, ok2 := eq.rightValue.(*IdCommon)
+		if !(ok1 && ok2) {
+			continue
+		}
+
+		switch {
+		case leftKeys[a.Key()] && rightKeys[b.Key()]:
+			return a.Key(), b.Key(), true
+		case leftKeys[b.Key()] && rightKeys[a.Key()]:
+			return b.Key(), a.Key(), true
+		}
+	}
+
+	return "", "", false
+}
+
+func conjuncts(condition Expression) []Expression {
+	if and, ok := condition.(*AndCommon); ok {
+		return append(conjuncts(and.leftValue), conjuncts(and.rightValue)...)
+	}
+	if condition == nil {
+		return nil
+	}
+	return []Expression{condition}
+}
+
+//joinRows evaluates the join condition for each candidate pair of rows enumerated by pairs. Matching
+//pairs are emitted first, followed by the unmatched rows of the preserved sides padded with NULL values.
+func joinRows(left JoinSource, right JoinSource, join JoinSelect, pairs func(leftRows, rightRows []map[string]interface{}, candidate func(int, int) error) error) (RowIterator, error) {
+	leftRows, err := left.collect()
+	if err != nil {
+		return nil, err
+	}
+
+	rightRows, err := right.collect()
+	if err != nil {
+		return nil, err
+	}
+
+	condition := join.Condition(left.Qualifier)
+	leftMatched := make([]bool, len(leftRows))
+	rightMatched := make([]bool, len(rightRows))
+	result := []map[string]interface{}{}
+
+	err = pairs(leftRows, rightRows, func(i, j int) error {
+		merged := mergeRows(leftRows[i], rightRows[j])
+
+		ok, err := EvaluatePredicate(condition, merged)
+		if err != nil || !ok {
+			return err
+		}
+
+		leftMatched[i], rightMatched[j] = true, true
+		result = append(result, join.mergeUsing(merged, left.Qualifier))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if join.PreservesLeft() {
+		padding := nullRow(right.Qualifier, right.Columns)
+		for i, row := range leftRows {
+			if !leftMatched[i] {
+				result = append(result, join.mergeUsing(mergeRows(row, padding), left.Qualifier))
+			}
+		}
+	}
+
+	if join.PreservesRight() {
+		padding := nullRow(left.Qualifier, left.Columns)
+		for j, row := range rightRows {
+			if !rightMatched[j] {
+				result = append(result, join.mergeUsing(mergeRows(padding, row), left.Qualifier))
+			}
+		}
+	}
+
+	return NewSliceIterator(result), nil
+}
+
+//mergeUsing adds the USING columns to a joined row without a qualifier, so that a following join can
+//use them with an empty left qualifier. Each takes the value of the left side unless it is NULL.
+func (j JoinSelect) mergeUsing(row map[string]interface{}, leftQualifier string) map[string]interface{} {
+	for _, column := range j.usingColumns {
+		value := row[qualify(leftQualifier, column)]
+		if value == nil {
+			value = row[qualify(j.Qualifier(), column)]
+		}
+		row[column] = value
+	}
+	return row
+}
+
+func mergeRows(left map[string]interface{}, right map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(left)+len(right))
+	for key, value := range left {
+		merged[key] = value
+	}
+	for key, value := range right {
+		merged[key] = value
+	}
+	return merged
+}
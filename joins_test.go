@@ -0,0 +1,207 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func joinSource(qualifier string, columns []string, rows ...[]interface{}) JoinSource {
+	symbols := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		symbols[i] = map[string]interface{}{}
+		for j, column := range columns {
+			symbols[i][column] = row[j]
+		}
+	}
+	return NewJoinSource(qualifier, columns, NewSliceIterator(symbols))
+}
+
+//joinedRows renders the selected keys of each row, sorted so that join algorithms can be compared.
+func joinedRows(t *testing.T, rows RowIterator, keys ...string) []string {
+	collected, err := CollectRows(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rendered := make([]string, len(collected))
+	for i, row := range collected {
+		values := make([]interface{}, len(keys))
+		for j, key := range keys {
+			values[j] = row[key]
+		}
+		rendered[i] = fmt.Sprint(values)
+	}
+	sort.Strings(rendered)
+	return rendered
+}
+
+func TestChainedUsingJoin(t *testing.T) {
+	a := joinSource("a", []string{"id", "x"}, []interface{}{int64(1), "a1"}, []interface{}{int64(2), "a2"})
+	b := joinSource("b", []string{"id", "y"}, []interface{}{int64(1), "b1"}, []interface{}{int64(3), "b3"})
+
+	first, err := Join(a, b, *NewJoinSelectWithKind(FullJoin, "b", "", nil, []string{"id"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ab := NewJoinSource("", []string{"a.id", "a.x", "b.id", "b.y", "id"}, first)
+	c := joinSource("c", []string{"id", "z"}, []interface{}{int64(2), "c2"}, []interface{}{int64(3), "c3"})
+
+	joined, err := Join(ab, c, *NewJoinSelectWithKind(InnerJoin, "c", "", nil, []string{"id"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"[2 a2 <nil> c2]", "[3 <nil> b3 c3]"}
+	if rows := joinedRows(t, joined, "id", "a.x", "b.y", "c.z"); fmt.Sprint(rows) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, rows)
+	}
+}
+
+func TestJoinKindsPadWithNull(t *testing.T) {
+	left := func() JoinSource {
+		return joinSource("a", []string{"id", "x"}, []interface{}{int64(1), "a1"}, []interface{}{int64(2), "a2"}, []interface{}{nil, "a3"})
+	}
+	right := func() JoinSource {
+		return joinSource("b", []string{"id", "y"}, []interface{}{int64(1), "b1"}, []interface{}{int64(3), "b3"}, []interface{}{nil, "b4"})
+	}
+	on := NewEqCommon(NewIdCommon("b", "id"), NewIdCommon("a", "id"))
+
+	tests := []struct {
+		kind     JoinKind
+		expected []string
+	}{
+		{InnerJoin, []string{"[a1 b1]"}},
+		{LeftJoin, []string{"[a1 b1]", "[a2 <nil>]", "[a3 <nil>]"}},
+		{RightJoin, []string{"[<nil> b3]", "[<nil> b4]", "[a1 b1]"}},
+		{FullJoin, []string{"[<nil> b3]", "[<nil> b4]", "[a1 b1]", "[a2 <nil>]", "[a3 <nil>]"}},
+	}
+
+	for _, test := range tests {
+		for _, join := range []*JoinSelect{NewJoinSelectWithKind(test.kind, "b", "", on, nil), NewJoinSelectWithKind(test.kind, "b", "", nil, []string{"id"})} {
+			joined, err := NestedLoopJoin(left(), right(), *join)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if rows := joinedRows(t, joined, "a.x", "b.y"); fmt.Sprint(rows) != fmt.Sprint(test.expected) {
+				t.Errorf("%s (USING %v): expected %v, got %v", test.kind, join.UsingColumns(), test.expected, rows)
+			}
+		}
+	}
+
+	joined, err := NestedLoopJoin(left(), right(), *NewJoinSelectWithKind(CrossJoin, "b", "", on, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := joinedRows(t, joined, "a.x", "b.y"); len(rows) != 9 {
+		t.Errorf("expected 9 rows from a CROSS JOIN, got %v", rows)
+	}
+}
+
+func TestUsingJoinMergesColumns(t *testing.T) {
+	left := joinSource("a", []string{"id"}, []interface{}{int64(1)}, []interface{}{int64(2)})
+	right := joinSource("b", []string{"id"}, []interface{}{int64(1)}, []interface{}{int64(3)})
+
+	joined, err := NestedLoopJoin(left, right, *NewJoinSelectWithKind(FullJoin, "b", "", nil, []string{"id"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"[1 1 1]", "[2 2 <nil>]", "[3 <nil> 3]"}
+	if rows := joinedRows(t, joined, "id", "a.id", "b.id"); fmt.Sprint(rows) != fmt.Sprint(expected) {
+		t.Errorf("expected %v, got %v", expected, rows)
+	}
+}
+
+func TestJoinAlgorithmsAgree(t *testing.T) {
+	left := func() JoinSource {
+		return joinSource("a", []string{"id", "x"},
+			[]interface{}{int64(1), int64(10)},
+			[]interface{}{int64(2), int64(20)},
+			[]interface{}{int64(2), int64(21)},
+			[]interface{}{nil, int64(30)},
+			[]interface{}{int64(4), int64(40)},
+		)
+	}
+	right := func() JoinSource {
+		return joinSource("b", []string{"id", "y"},
+			[]interface{}{2.0, int64(15)},
+			[]interface{}{int64(2), int64(25)},
+			[]interface{}{int64(1), int64(5)},
+			[]interface{}{nil, int64(35)},
+			[]interface{}{int64(5), int64(50)},
+		)
+	}
+
+	keys := NewEqCommon(NewIdCommon("b", "id"), NewIdCommon("a", "id"))
+	conditions := map[string]Expression{
+		"equality":           keys,
+		"reversed equality":  NewEqCommon(NewIdCommon("a", "id"), NewIdCommon("b", "id")),
+		"residual condition": NewAndCommon(NewLtCommon(NewIdCommon("a", "x"), NewIdCommon("b", "y")), keys),
+	}
+
+	algorithms := map[string]func(JoinSource, JoinSource, JoinSelect) (RowIterator, error){
+		"hash":       HashJoin,
+		"sort-merge": SortMergeJoin,
+		"join":       Join,
+	}
+
+	for name, condition := range conditions {
+		for _, kind := range []JoinKind{InnerJoin, LeftJoin, RightJoin, FullJoin} {
+			join := *NewJoinSelectWithKind(kind, "b", "", condition, nil)
+
+			nested, err := NestedLoopJoin(left(), right(), join)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := joinedRows(t, nested, "a.id", "a.x", "b.id", "b.y")
+
+			for algorithm, joinFunc := range algorithms {
+				joined, err := joinFunc(left(), right(), join)
+				if err != nil {
+					t.Errorf("%s %s with %s: unexpected error %v", algorithm, kind, name, err)
+					continue
+				}
+
+				if rows := joinedRows(t, joined, "a.id", "a.x", "b.id", "b.y"); fmt.Sprint(rows) != fmt.Sprint(expected) {
+					t.Errorf("%s %s with %s: expected %v, got %v", algorithm, kind, name, expected, rows)
+				}
+			}
+		}
+	}
+}
+
+func TestJoinRequiresEquiJoin(t *testing.T) {
+	source := func() JoinSource {
+		return joinSource("a", []string{"id"}, []interface{}{int64(1)})
+	}
+	target := func() JoinSource {
+		return joinSource("b", []string{"id"}, []interface{}{int64(1)})
+	}
+
+	joins := []JoinSelect{
+		*NewJoinSelect("b", "", NewLtCommon(NewIdCommon("b", "id"), NewIdCommon("a", "id"))),
+		*NewJoinSelect("b", "", NewOrCommon(NewTrueCommon(), NewEqCommon(NewIdCommon("b", "id"), NewIdCommon("a", "id")))),
+		*NewJoinSelect("b", "", NewEqCommon(NewIntCommon(1), NewIdCommon("a", "id"))),
+		*NewJoinSelectWithKind(CrossJoin, "b", "", NewEqCommon(NewIdCommon("b", "id"), NewIdCommon("a", "id")), nil),
+	}
+
+	for _, join := range joins {
+		for name, joinFunc := range map[string]func(JoinSource, JoinSource, JoinSelect) (RowIterator, error){"hash": HashJoin, "sort-merge": SortMergeJoin} {
+			if _, err := joinFunc(source(), target(), join); err != ErrNotEquiJoin {
+				t.Errorf("%s join on %v: expected ErrNotEquiJoin, got %v", name, join.Condition("a"), err)
+			}
+		}
+
+		joined, err := Join(source(), target(), join)
+		if err != nil {
+			t.Errorf("join on %v: unexpected error %v", join.Condition("a"), err)
+			continue
+		}
+		if _, err := CollectRows(joined); err != nil {
+			t.Errorf("join on %v: unexpected error %v", join.Condition("a"), err)
+		}
+	}
+}
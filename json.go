@@ -0,0 +1,919 @@
+package common
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+	"unicode/utf8"
+)
+
+//jsonNode is the JSON representation of an expression node. Type is the discriminator and only the
+//fields used by that node type are present. Binary operators keep their operands in Left and Right
+//in evaluation order.
+type jsonNode struct {
+	Type            string          `json:"type"`
+	Name            string          `json:"name,omitempty"`
+	Alias           string          `json:"alias,omitempty"`
+	Value           json.RawMessage `json:"value,omitempty"`
+	Left            *jsonNode       `json:"left,omitempty"`
+	Right           *jsonNode       `json:"right,omitempty"`
+	Operand         *jsonNode       `json:"operand,omitempty"`
+	Lower           *jsonNode       `json:"lower,omitempty"`
+	Upper           *jsonNode       `json:"upper,omitempty"`
+	List            []*jsonNode     `json:"list,omitempty"`
+	Whens           []jsonWhen      `json:"whens,omitempty"`
+	Else            *jsonNode       `json:"else,omitempty"`
+	Query           *jsonSelect     `json:"query,omitempty"`
+	Escape          string          `json:"escape,omitempty"`
+	CaseInsensitive bool            `json:"caseInsensitive,omitempty"`
+	Symmetric       bool            `json:"symmetric,omitempty"`
+	Truth           bool            `json:"truth,omitempty"`
+	Distinct        bool            `json:"distinct,omitempty"`
+	Not             bool            `json:"not,omitempty"`
+}
+
+type jsonWhen struct {
+	Condition *jsonNode `json:"condition"`
+	Result    *jsonNode `json:"result"`
+}
+
+//jsonValue is the JSON representation of a value held by a command, such as an inserted value or a
+//column default. Its type keeps integers, floats and datetimes apart.
+type jsonValue struct {
+	Type       string          `json:"type"`
+	Value      json.RawMessage `json:"value,omitempty"`
+	Expression *jsonNode       `json:"expression,omitempty"`
+}
+
+type jsonDefiner struct {
+	Type              string     `json:"type"`
+	Name              string     `json:"name"`
+	Default           *jsonValue `json:"default,omitempty"`
+	Nullable          bool       `json:"nullable,omitempty"`
+	Autoincrementable bool       `json:"autoincrementable,omitempty"`
+	PrimaryKey        bool       `json:"primaryKey,omitempty"`
+	ForeignKey        bool       `json:"foreignKey,omitempty"`
+	Size              uint16     `json:"size,omitempty"`
+}
+
+type jsonSelector struct {
+	Type     string    `json:"type"`
+	IsStar   bool      `json:"isStar,omitempty"`
+	Prefix   string    `json:"prefix,omitempty"`
+	Column   string    `json:"column,omitempty"`
+	Alias    string    `json:"alias,omitempty"`
+	Function *jsonNode `json:"function,omitempty"`
+}
+
+type jsonJoin struct {
+	Kind  string    `json:"kind"`
+	Table string    `json:"table"`
+	Alias string    `json:"alias,omitempty"`
+	On    *jsonNode `json:"on,omitempty"`
+	Using []string  `json:"using,omitempty"`
+}
+
+type jsonGroupBy struct {
+	Table  string `json:"table,omitempty"`
+	Column string `json:"column"`
+}
+
+type jsonOrderBy struct {
+	Expression *jsonNode `json:"expression"`
+	Descending bool      `json:"descending,omitempty"`
+	Nulls      string    `json:"nulls,omitempty"`
+}
+
+type jsonSelect struct {
+	Table    string         `json:"table"`
+	Alias    string         `json:"alias,omitempty"`
+	Columns  []jsonSelector `json:"columns"`
+	Joins    []jsonJoin     `json:"joins,omitempty"`
+	Where    *jsonNode      `json:"where,omitempty"`
+	GroupBy  []jsonGroupBy  `json:"groupBy,omitempty"`
+	Having   *jsonNode      `json:"having,omitempty"`
+	OrderBy  []jsonOrderBy  `json:"orderBy,omitempty"`
+	Limit    *int64         `json:"limit,omitempty"`
+	Offset   int64          `json:"offset,omitempty"`
+	Distinct bool           `json:"distinct,omitempty"`
+}
+
+type jsonAssignment struct {
+	Column     string    `json:"column"`
+	Expression *jsonNode `json:"expression"`
+}
+
+type jsonAlter struct {
+	Type    string       `json:"type"`
+	Column  string       `json:"column,omitempty"`
+	Definer *jsonDefiner `json:"definer,omitempty"`
+}
+
+//jsonCommand is the JSON representation of the table modifier of a Command. Type is the discriminator.
+type jsonCommand struct {
+	Type        string                `json:"type"`
+	Table       string                `json:"table,omitempty"`
+	Alias       string                `json:"alias,omitempty"`
+	Columns     []jsonDefiner         `json:"columns,omitempty"`
+	Query       *jsonSelect           `json:"query,omitempty"`
+	Left        *jsonSelect           `json:"left,omitempty"`
+	Right       *jsonSelect           `json:"right,omitempty"`
+	Operator    string                `json:"operator,omitempty"`
+	All         bool                  `json:"all,omitempty"`
+	Values      map[string]*jsonValue `json:"values,omitempty"`
+	Assignments []jsonAssignment      `json:"assignments,omitempty"`
+	Where       *jsonNode             `json:"where,omitempty"`
+	Instruction *jsonAlter            `json:"instruction,omitempty"`
+}
+
+type jsonEnvelope struct {
+	InstructionType string       `json:"instructionType"`
+	Command         *jsonCommand `json:"command"`
+}
+
+var nullsOrderName = map[NullsOrder]string{
+	NullsDefault: "",
+	NullsFirst:   "FIRST",
+	NullsLast:    "LAST",
+}
+
+//MarshalJSON encodes the command and its instruction type. The Instruction function is not encoded.
+func (c Command) MarshalJSON() ([]byte, error) {
+	enc := &jsonEncoder{}
+	envelope := jsonEnvelope{c.InstructionType.String(), enc.command(c.tableModifier)}
+	if enc.err != nil {
+		return nil, enc.err
+	}
+	return json.Marshal(envelope)
+}
+
+//UnmarshalJSON decodes a command encoded by MarshalJSON. The decoded command has no Instruction function.
+func (c *Command) UnmarshalJSON(data []byte) error {
+	var envelope jsonEnvelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return err
+	}
+
+	dec := &jsonDecoder{}
+	instructionType := dec.instructionType(envelope.InstructionType)
+	tableModifier := dec.command(envelope.Command)
+	if dec.err != nil {
+		return dec.err
+	}
+
+	*c = NewCommand(tableModifier, instructionType, nil)
+	return nil
+}
+
+//MarshalExpression encodes an expression tree as JSON, tagging every node with its type.
+func MarshalExpression(expression Expression) ([]byte, error) {
+	enc := &jsonEncoder{}
+	node := enc.expression(expression)
+	if enc.err != nil {
+		return nil, enc.err
+	}
+	return json.Marshal(node)
+}
+
+//UnmarshalExpression decodes an expression tree encoded by MarshalExpression.
+func UnmarshalExpression(data []byte) (Expression, error) {
+	var node *jsonNode
+	if err := json.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+
+	dec := &jsonDecoder{}
+	expression := dec.expression(node)
+	return expression, dec.err
+}
+
+//MarshalTableColumnDefiner encodes a column definition as JSON, tagging it with its column type.
+func MarshalTableColumnDefiner(definer TableColumnDefiner) ([]byte, error) {
+	enc := &jsonEncoder{}
+	encoded := enc.definer(definer)
+	if enc.err != nil {
+		return nil, enc.err
+	}
+	return json.Marshal(encoded)
+}
+
+//UnmarshalTableColumnDefiner decodes a column definition encoded by MarshalTableColumnDefiner.
+func UnmarshalTableColumnDefiner(data []byte) (TableColumnDefiner, error) {
+	var encoded jsonDefiner
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return nil, err
+	}
+
+	dec := &jsonDecoder{}
+	definer := dec.definer(encoded)
+	return definer, dec.err
+}
+
+//jsonEncoder converts commands and expressions into their JSON representation. It keeps the
+//first error found so that nested values can be encoded without checking every step.
+type jsonEncoder struct {
+	err error
+}
+
+func (enc *jsonEncoder) fail(err error) {
+	if enc.err == nil {
+		enc.err = err
+	}
+}
+
+func (enc *jsonEncoder) raw(value interface{}) json.RawMessage {
+	data, err := json.Marshal(value)
+	if err != nil {
+		enc.fail(err)
+	}
+	return data
+}
+
+//float encodes a float, writing NaN and the infinities, which JSON numbers can't hold, as the strings
+//"NaN", "Infinity" and "-Infinity".
+func (enc *jsonEncoder) float(value float64) json.RawMessage {
+	switch {
+	case math.IsNaN(value):
+		return enc.raw("NaN")
+	case math.IsInf(value, 1):
+		return enc.raw("Infinity")
+	case math.IsInf(value, -1):
+		return enc.raw("-Infinity")
+	}
+	return enc.raw(value)
+}
+
+func (enc *jsonEncoder) binary(nodeType string, left Expression, right Expression) *jsonNode {
+	return &jsonNode{Type: nodeType, Left: enc.expression(left), Right: enc.expression(right)}
+}
+
+func (enc *jsonEncoder) expressions(expressions []Expression) []*jsonNode {
+	nodes := make([]*jsonNode, len(expressions))
+	for i, expression := range expressions {
+		nodes[i] = enc.expression(expression)
+	}
+	return nodes
+}
+
+func (enc *jsonEncoder) expression(expression Expression) *jsonNode {
+	if expression == nil || enc.err != nil {
+		return nil
+	}
+
+	switch e := expression.(type) {
+	case *IdCommon:
+		return &jsonNode{Type: "id", Name: e.name, Alias: e.alias}
+	case *IntCommon:
+		return &jsonNode{Type: "int", Value: enc.raw(e.value)}
+	case *FloatCommon:
+		return &jsonNode{Type: "float", Value: enc.float(e.value)}
+	case *BoolCommon:
+		return &jsonNode{Type: "bool", Value: enc.raw(e.value)}
+	case *StringCommon:
+		return &jsonNode{Type: "string", Value: enc.raw(e.value)}
+	case *NullCommon:
+		return &jsonNode{Type: "null"}
+	case *TrueCommon:
+		return &jsonNode{Type: "true"}
+	case *FalseCommon:
+		return &jsonNode{Type: "false"}
+	case *SumCommon:
+		return enc.binary("sum", e.leftValue, e.rightValue)
+	case *SubCommon:
+		return enc.binary("sub", e.leftValue, e.rightValue)
+	case *MultCommon:
+		return enc.binary("mult", e.leftValue, e.rightValue)
+	case *DivCommon:
+		return enc.binary("div", e.leftValue, e.rightValue)
+	case *EqCommon:
+		return enc.binary("eq", e.leftValue, e.rightValue)
+	case *NeCommon:
+		return enc.binary("ne", e.leftValue, e.rightValue)
+	case *LtCommon:
+		return enc.binary("lt", e.leftValue, e.rightValue)
+	case *GtCommon:
+		return enc.binary("gt", e.leftValue, e.rightValue)
+	case *LteCommon:
+		return enc.binary("lte", e.leftValue, e.rightValue)
+	case *GteCommon:
+		return enc.binary("gte", e.leftValue, e.rightValue)
+	case *AndCommon:
+		return enc.binary("and", e.leftValue, e.rightValue)
+	case *OrCommon:
+		return enc.binary("or", e.leftValue, e.rightValue)
+	case *NotCommon:
+		return &jsonNode{Type: "not", Operand: enc.expression(e.not)}
+	case *LikeCommon:
+		node := enc.binary("like", e.leftValue, e.rightValue)
+		if e.escape != 0 {
+			node.Escape = string(e.escape)
+		}
+		node.CaseInsensitive = e.caseInsensitive
+		return node
+	case *BetweenCommon:
+		return &jsonNode{
+			Type:      "between",
+			Operand:   enc.expression(e.value),
+			Lower:     enc.expression(e.lower),
+			Upper:     enc.expression(e.upper),
+			Not:       e.not,
+			Symmetric: e.symmetric,
+		}
+	case *InCommon:
+		return &jsonNode{Type: "in", Operand: enc.expression(e.value), List: enc.expressions(e.list), Not: e.not}
+	case *InSelectCommon:
+		return &jsonNode{Type: "inSelect", Operand: enc.expression(e.value), Query: enc.query(e.query), Not: e.not}
+	case *ExistsCommon:
+		return &jsonNode{Type: "exists", Query: enc.query(e.query), Not: e.not}
+	case *ScalarSubqueryCommon:
+		return &jsonNode{Type: "scalarSubquery", Query: enc.query(e.query)}
+	case *IsNullCommon:
+		return &jsonNode{Type: "isNull", Operand: enc.expression(e.value), Not: e.not}
+	case *IsBoolCommon:
+		return &jsonNode{Type: "isBool", Operand: enc.expression(e.value), Truth: e.truth, Not: e.not}
+	case *IsDistinctFromCommon:
+		node := enc.binary("isDistinctFrom", e.leftValue, e.rightValue)
+		node.Not = e.not
+		return node
+	case *CaseCommon:
+		node := &jsonNode{Type: "case", Operand: enc.expression(e.operand), Else: enc.expression(e.elseValue)}
+		node.Whens = make([]jsonWhen, len(e.whens))
+		for i, when := range e.whens {
+			node.Whens[i] = jsonWhen{enc.expression(when.condition), enc.expression(when.result)}
+		}
+		return node
+	case *FuncCallCommon:
+		return &jsonNode{Type: "funcCall", Name: e.name, List: enc.expressions(e.args)}
+	case *AggregateCommon:
+		return &jsonNode{Type: "aggregate", Name: e.name, Operand: enc.expression(e.argument), Distinct: e.distinct}
+	}
+
+	enc.fail(UnsupportedValueError{expression})
+	return nil
+}
+
+func (enc *jsonEncoder) value(value interface{}) *jsonValue {
+	switch v := value.(type) {
+	case nil:
+		return &jsonValue{Type: "null"}
+	case int64:
+		return &jsonValue{Type: "int", Value: enc.raw(v)}
+	case int:
+		return &jsonValue{Type: "int", Value: enc.raw(v)}
+	case float64:
+		return &jsonValue{Type: "float", Value: enc.float(v)}
+	case bool:
+		return &jsonValue{Type: "bool", Value: enc.raw(v)}
+	case string:
+		return &jsonValue{Type: "string", Value: enc.raw(v)}
+	case time.Time:
+		return &jsonValue{Type: "datetime", Value: enc.raw(v)}
+	case Expression:
+		return &jsonValue{Type: "expression", Expression: enc.expression(v)}
+	}
+
+	enc.fail(UnsupportedValueError{value})
+	return nil
+}
+
+func (enc *jsonEncoder) definer(definer TableColumnDefiner) jsonDefiner {
+	encoded := jsonDefiner{
+		Type:              ColumnType(definer).String(),
+		Name:              definer.ColumnName(),
+		Nullable:          definer.Nullable(),
+		Autoincrementable: definer.Autoincrementable(),
+		PrimaryKey:        definer.PrimaryKey(),
+		ForeignKey:        definer.ForeignKey(),
+	}
+
+	if definer.DefaultValue() != nil {
+		encoded.Default = enc.value(definer.DefaultValue())
+	}
+
+	switch c := definer.(type) {
+	case CharTableColumn:
+		encoded.Size = c.size
+	case *CharTableColumn:
+		encoded.Size = c.size
+	case IntegerTableColumn, *IntegerTableColumn, FloatTableColumn, *FloatTableColumn,
+		BooleanTableColumn, *BooleanTableColumn, DatetimeTableColumn, *DatetimeTableColumn:
+	default:
+		enc.fail(UnsupportedValueError{definer})
+	}
+
+	return encoded
+}
+
+func (enc *jsonEncoder) selector(selector interface{}) jsonSelector {
+	switch s := selector.(type) {
+	case TableColumnStarSelector, *TableColumnStarSelector:
+		return jsonSelector{Type: "star"}
+	case TableColumnSelector:
+		return enc.selector(&s)
+	case *TableColumnSelector:
+		encoded := jsonSelector{Type: "column", IsStar: s.isStar, Prefix: s.prefix, Column: s.columnName, Alias: s.alias}
+		if s.function != nil {
+			encoded.Function = enc.expression(s.function)
+		}
+		return encoded
+	}
+
+	enc.fail(UnsupportedValueError{selector})
+	return jsonSelector{}
+}
+
+func (enc *jsonEncoder) query(query *SelectTableCommand) *jsonSelect {
+	if query == nil || enc.err != nil {
+		return nil
+	}
+
+	encoded := &jsonSelect{
+		Table:    query.tableName,
+		Alias:    query.mainAlias,
+		Columns:  make([]jsonSelector, len(query.tableColumnSelectors)),
+		Where:    enc.expression(query.whereExpression),
+		Having:   enc.expression(query.havingExpression),
+		Offset:   query.offset,
+		Distinct: query.distinct,
+	}
+	if limit, ok := query.Limit(); ok {
+		encoded.Limit = &limit
+	}
+
+	for i, selector := range query.tableColumnSelectors {
+		encoded.Columns[i] = enc.selector(selector)
+	}
+	for _, join := range query.joinList {
+		encoded.Joins = append(encoded.Joins, jsonJoin{
+			Kind:  join.kind.String(),
+			Table: join.targetTable,
+			Alias: join.targetAlias,
+			On:    enc.expression(join.filterCriteria),
+			Using: join.usingColumns,
+		})
+	}
+	for _, column := range query.groupBy {
+		encoded.GroupBy = append(encoded.GroupBy, jsonGroupBy{column.table, column.column})
+	}
+	for _, order := range query.orderBy {
+		encoded.OrderBy = append(encoded.OrderBy, jsonOrderBy{enc.expression(order.expression), order.descending, nullsOrderName[order.nulls]})
+	}
+
+	return encoded
+}
+
+func (enc *jsonEncoder) alter(instruction interface{}) *jsonAlter {
+	switch i := instruction.(type) {
+	case *AlterDropInst:
+		return &jsonAlter{Type: "drop", Column: i.table}
+	case *AlterAddInst:
+		definer := enc.definer(i.tableColumnDefiners)
+		return &jsonAlter{Type: "add", Definer: &definer}
+	case *AlterModifyInst:
+		definer := enc.definer(i.tableColumnDefiners)
+		return &jsonAlter{Type: "modify", Definer: &definer}
+	}
+
+	enc.fail(UnsupportedValueError{instruction})
+	return nil
+}
+
+func (enc *jsonEncoder) command(tableModifier tableModifier) *jsonCommand {
+	switch c := tableModifier.(type) {
+	case *CreateTableCommand:
+		encoded := &jsonCommand{Type: "create", Table: c.tableName, Columns: make([]jsonDefiner, len(c.tableColumnDefiners))}
+		for i, definer := range c.tableColumnDefiners {
+			encoded.Columns[i] = enc.definer(definer)
+		}
+		return encoded
+	case *SelectTableCommand:
+		return &jsonCommand{Type: "select", Query: enc.query(c)}
+	case *CompoundSelectCommand:
+		return &jsonCommand{Type: "compoundSelect", Left: enc.query(c.left), Right: enc.query(c.right), Operator: c.operator.String(), All: c.all}
+	case *InsertCommand:
+		encoded := &jsonCommand{Type: "insert", Table: c.tableName, Values: map[string]*jsonValue{}}
+		for column, value := range c.values {
+			encoded.Values[column] = enc.value(value)
+		}
+		return encoded
+	case *UpdateTableCommand:
+		encoded := &jsonCommand{Type: "update", Table: c.tableName, Where: enc.expression(c.where)}
+		for _, assignment := range c.assignments {
+			encoded.Assignments = append(encoded.Assignments, jsonAssignment{assignment.value, enc.expression(assignment.expression)})
+		}
+		return encoded
+	case *DeleteCommand:
+		return &jsonCommand{Type: "delete", Table: c.tableName, Alias: c.alias, Where: enc.expression(c.where)}
+	case *DropCommand:
+		return &jsonCommand{Type: "drop", Table: c.tableName}
+	case *AlterCommand:
+		return &jsonCommand{Type: "alter", Table: c.table, Instruction: enc.alter(c.instruction)}
+	}
+
+	enc.fail(UnsupportedValueError{tableModifier})
+	return nil
+}
+
+//jsonDecoder rebuilds commands and expressions from their JSON representation, keeping the first error found.
+type jsonDecoder struct {
+	err error
+}
+
+func (dec *jsonDecoder) fail(err error) {
+	if dec.err == nil {
+		dec.err = err
+	}
+}
+
+func (dec *jsonDecoder) unmarshal(data json.RawMessage, value interface{}) {
+	if dec.err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		dec.fail(err)
+	}
+}
+
+//float decodes a float encoded by jsonEncoder.float.
+func (dec *jsonDecoder) float(data json.RawMessage) float64 {
+	var name string
+	if json.Unmarshal(data, &name) == nil {
+		switch name {
+		case "NaN":
+			return math.NaN()
+		case "Infinity":
+			return math.Inf(1)
+		case "-Infinity":
+			return math.Inf(-1)
+		}
+	}
+
+	var value float64
+	dec.unmarshal(data, &value)
+	return value
+}
+
+//operand decodes a required operand of a node of type parent, failing with a MissingOperandError when it is missing or null.
+func (dec *jsonDecoder) operand(parent string, node *jsonNode) Expression {
+	if node == nil {
+		dec.fail(MissingOperandError{parent})
+		return nil
+	}
+	return dec.expression(node)
+}
+
+//operands decodes the operands of a binary node, returned in the order expected by the node constructors.
+func (dec *jsonDecoder) operands(node *jsonNode) (value Expression, expression Expression) {
+	return dec.operand(node.Type, node.Right), dec.operand(node.Type, node.Left)
+}
+
+func (dec *jsonDecoder) expressions(parent string, nodes []*jsonNode) []Expression {
+	if nodes == nil {
+		return nil
+	}
+
+	expressions := make([]Expression, len(nodes))
+	for i, node := range nodes {
+		expressions[i] = dec.operand(parent, node)
+	}
+	return expressions
+}
+
+//subquery decodes the required query of a subquery node.
+func (dec *jsonDecoder) subquery(parent string, query *jsonSelect) *SelectTableCommand {
+	if query == nil {
+		dec.fail(MissingOperandError{parent})
+		return nil
+	}
+	return dec.query(query)
+}
+
+func (dec *jsonDecoder) expression(node *jsonNode) Expression {
+	if node == nil || dec.err != nil {
+		return nil
+	}
+
+	switch node.Type {
+	case "id":
+		return NewIdCommon(node.Name, node.Alias)
+	case "int":
+		var value int64
+		dec.unmarshal(node.Value, &value)
+		return NewIntCommon(value)
+	case "float":
+		return NewFloatCommon(dec.float(node.Value))
+	case "bool":
+		var value bool
+		dec.unmarshal(node.Value, &value)
+		return NewBoolCommon(value)
+	case "string":
+		var value string
+		dec.unmarshal(node.Value, &value)
+		return NewStringCommon(value)
+	case "null":
+		return NewNullCommon()
+	case "true":
+		return NewTrueCommon()
+	case "false":
+		return NewFalseCommon()
+	case "sum":
+		return NewSumCommon(dec.operands(node))
+	case "sub":
+		return NewSubCommon(dec.operands(node))
+	case "mult":
+		return NewMultCommon(dec.operands(node))
+	case "div":
+		return NewDivCommon(dec.operands(node))
+	case "eq":
+		return NewEqCommon(dec.operands(node))
+	case "ne":
+		return NewNeCommon(dec.operands(node))
+	case "lt":
+		return NewLtCommon(dec.operands(node))
+	case "gt":
+		return NewGtCommon(dec.operands(node))
+	case "lte":
+		return NewLteCommon(dec.operands(node))
+	case "gte":
+		return NewGteCommon(dec.operands(node))
+	case "and":
+		return NewAndCommon(dec.operands(node))
+	case "or":
+		return NewOrCommon(dec.operands(node))
+	case "not":
+		return NewNotCommon(dec.operand(node.Type, node.Operand))
+	case "like":
+		value, expression := dec.operands(node)
+		return NewLikeEscapeCommon(value, expression, dec.escape(node.Escape), node.CaseInsensitive)
+	case "between":
+		return NewBetweenCommon(dec.operand(node.Type, node.Operand), dec.operand(node.Type, node.Lower), dec.operand(node.Type, node.Upper), node.Not, node.Symmetric)
+	case "in":
+		return NewInCommon(dec.operand(node.Type, node.Operand), dec.expressions(node.Type, node.List), node.Not)
+	case "inSelect":
+		return NewInSelectCommon(dec.operand(node.Type, node.Operand), dec.subquery(node.Type, node.Query), node.Not)
+	case "exists":
+		return NewExistsCommon(dec.subquery(node.Type, node.Query), node.Not)
+	case "scalarSubquery":
+		return NewScalarSubqueryCommon(dec.subquery(node.Type, node.Query))
+	case "isNull":
+		return NewIsNullCommon(dec.operand(node.Type, node.Operand), node.Not)
+	case "isBool":
+		return NewIsBoolCommon(dec.operand(node.Type, node.Operand), node.Truth, node.Not)
+	case "isDistinctFrom":
+		value, expression := dec.operands(node)
+		return NewIsDistinctFromCommon(value, expression, node.Not)
+	case "case":
+		if len(node.Whens) == 0 {
+			dec.fail(MissingOperandError{node.Type})
+			return nil
+		}
+
+		whens := make([]*WhenCommon, len(node.Whens))
+		for i, when := range node.Whens {
+			whens[i] = NewWhenCommon(dec.operand(node.Type, when.Condition), dec.operand(node.Type, when.Result))
+		}
+
+		operand, elseValue := dec.expression(node.Operand), dec.expression(node.Else)
+		if dec.err != nil {
+			return nil
+		}
+		return NewCaseCommon(operand, whens, elseValue)
+	case "funcCall":
+		return NewFuncCallCommon(node.Name, dec.expressions(node.Type, node.List))
+	case "aggregate":
+		return NewAggregateCommon(node.Name, dec.expression(node.Operand), node.Distinct)
+	}
+
+	dec.fail(UnknownNodeTypeError{node.Type})
+	return nil
+}
+
+func (dec *jsonDecoder) escape(escape string) rune {
+	if escape == "" {
+		return 0
+	}
+
+	r, size := utf8.DecodeRuneInString(escape)
+	if size != len(escape) {
+		dec.fail(LikePatternError{escape, "ESCAPE must be a single character"})
+	}
+	return r
+}
+
+func (dec *jsonDecoder) value(value *jsonValue) interface{} {
+	if value == nil || dec.err != nil {
+		return nil
+	}
+
+	switch value.Type {
+	case "null":
+		return nil
+	case "int":
+		var v int64
+		dec.unmarshal(value.Value, &v)
+		return v
+	case "float":
+		return dec.float(value.Value)
+	case "bool":
+		var v bool
+		dec.unmarshal(value.Value, &v)
+		return v
+	case "string":
+		var v string
+		dec.unmarshal(value.Value, &v)
+		return v
+	case "datetime":
+		var v time.Time
+		dec.unmarshal(value.Value, &v)
+		return v
+	case "expression":
+		return dec.operand(value.Type, value.Expression)
+	}
+
+	dec.fail(UnknownNodeTypeError{value.Type})
+	return nil
+}
+
+func (dec *jsonDecoder) definer(definer jsonDefiner) TableColumnDefiner {
+	defaultValue := dec.value(definer.Default)
+	base := baseTableColumn{definer.Name, defaultValue, definer.Nullable, definer.Autoincrementable, definer.PrimaryKey, definer.ForeignKey}
+
+	switch definer.Type {
+	case IntegerType.String():
+		return IntegerTableColumn{base}
+	case FloatType.String():
+		return FloatTableColumn{base}
+	case BooleanType.String():
+		return BooleanTableColumn{base}
+	case DatetimeType.String():
+		return DatetimeTableColumn{base}
+	case CharType.String():
+		return CharTableColumn{base, definer.Size}
+	}
+
+	dec.fail(UnknownNodeTypeError{definer.Type})
+	return nil
+}
+
+func (dec *jsonDecoder) selector(selector jsonSelector) interface{} {
+	switch selector.Type {
+	case "star":
+		return NewTableColumnStarSelector()
+	case "column":
+		var function Expression
+		if selector.Function != nil {
+			function = dec.expression(selector.Function)
+		}
+		return NewTableColumnSelector(selector.IsStar, selector.Prefix, selector.Column, selector.Alias, function)
+	}
+
+	dec.fail(UnknownNodeTypeError{selector.Type})
+	return nil
+}
+
+func (dec *jsonDecoder) joinKind(name string) JoinKind {
+	for kind, kindName := range joinKindName {
+		if kindName == name {
+			return kind
+		}
+	}
+
+	dec.fail(UnknownNodeTypeError{name})
+	return InnerJoin
+}
+
+func (dec *jsonDecoder) nullsOrder(name string) NullsOrder {
+	for nulls, nullsName := range nullsOrderName {
+		if nullsName == name {
+			return nulls
+		}
+	}
+
+	dec.fail(UnknownNodeTypeError{name})
+	return NullsDefault
+}
+
+func (dec *jsonDecoder) setOperator(name string) SetOperator {
+	for operator, operatorName := range setOperatorName {
+		if operatorName == name {
+			return operator
+		}
+	}
+
+	dec.fail(UnknownNodeTypeError{name})
+	return Union
+}
+
+func (dec *jsonDecoder) instructionType(name string) InstructionType {
+	for instructionType, instructionTypeName := range instructionName {
+		if instructionTypeName == name {
+			return instructionType
+		}
+	}
+
+	dec.fail(UnknownNodeTypeError{name})
+	return Create
+}
+
+func (dec *jsonDecoder) query(query *jsonSelect) *SelectTableCommand {
+	if query == nil || dec.err != nil {
+		return nil
+	}
+
+	selectors := make(TableColumnSelectors, len(query.Columns))
+	for i, selector := range query.Columns {
+		selectors[i] = dec.selector(selector)
+	}
+
+	var joins []JoinSelect
+	for _, join := range query.Joins {
+		joins = append(joins, *NewJoinSelectWithKind(dec.joinKind(join.Kind), join.Table, join.Alias, dec.expression(join.On), join.Using))
+	}
+
+	var groupBy []GroupBySelect
+	for _, column := range query.GroupBy {
+		groupBy = append(groupBy, *NewGroupBySelect(column.Table, column.Column))
+	}
+
+	var orderBy []OrderBySelect
+	for _, order := range query.OrderBy {
+		orderBy = append(orderBy, *NewOrderBySelect(dec.operand("orderBy", order.Expression), order.Descending, dec.nullsOrder(order.Nulls)))
+	}
+
+	var limit int64
+	if query.Limit != nil {
+		limit = *query.Limit
+	}
+
+	return NewSelectTableCommand(query.Table, query.Alias, selectors, joins, dec.expression(query.Where), groupBy,
+		dec.expression(query.Having), orderBy, limit, query.Limit != nil, query.Offset, query.Distinct)
+}
+
+func (dec *jsonDecoder) alter(instruction *jsonAlter) interface{} {
+	if instruction == nil {
+		dec.fail(UnknownNodeTypeError{""})
+		return nil
+	}
+
+	switch instruction.Type {
+	case "drop":
+		return NewAlterDropInst(instruction.Column)
+	case "add", "modify":
+		if instruction.Definer == nil {
+			break
+		}
+		definer := dec.definer(*instruction.Definer)
+		if instruction.Type == "add" {
+			return NewAlterAddInst(definer)
+		}
+		return NewAlterModifyInst(definer)
+	}
+
+	dec.fail(UnknownNodeTypeError{instruction.Type})
+	return nil
+}
+
+func (dec *jsonDecoder) command(command *jsonCommand) tableModifier {
+	if command == nil {
+		dec.fail(UnknownNodeTypeError{""})
+		return nil
+	}
+
+	switch command.Type {
+	case "create":
+		definers := make(TableColumnDefiners, len(command.Columns))
+		for i, definer := range command.Columns {
+			definers[i] = dec.definer(definer)
+		}
+		return NewCreateTableCommand(command.Table, definers)
+	case "select":
+		if command.Query != nil {
+			return dec.query(command.Query)
+		}
+	case "compoundSelect":
+		if command.Left != nil && command.Right != nil {
+			return NewCompoundSelectCommand(dec.query(command.Left), dec.query(command.Right), dec.setOperator(command.Operator), command.All)
+		}
+	case "insert":
+		values := map[string]interface{}{}
+		for column, value := range command.Values {
+			values[column] = dec.value(value)
+		}
+		return NewInsertCommand(command.Table, values)
+	case "update":
+		assignments := make([]*AssignmentCommon, len(command.Assignments))
+		for i, assignment := range command.Assignments {
+			assignments[i] = NewAssignmentCommon(assignment.Column, dec.operand("assignment", assignment.Expression))
+		}
+		return NewUpdateTableCommand(command.Table, assignments, dec.expression(command.Where))
+	case "delete":
+		return NewDeleteTableCommand(command.Table, command.Alias, dec.expression(command.Where))
+	case "drop":
+		return NewDropCommand(command.Table)
+	case "alter":
+		return NewAlterCommand(command.Table, dec.alter(command.Instruction))
+	}
+
+	dec.fail(UnknownNodeTypeError{command.Type})
+	return nil
+}
@@ -0,0 +1,226 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//sampleQuery returns a select query using every clause.
+func sampleQuery() *SelectTableCommand {
+	a, b := NewIdCommon("a", ""), NewIdCommon("t", "b")
+
+	selectors := TableColumnSelectors{
+		NewTableColumnStarSelector(),
+		NewTableColumnSelector(false, "t", "b", "total", nil),
+		NewTableColumnSelector(false, "", "", "upper", NewFuncCallCommon("UPPER", []Expression{a})),
+	}
+	joins := []JoinSelect{
+		*NewJoinSelect("u", "v", NewEqCommon(b, NewIdCommon("v", "b"))),
+		*NewJoinSelectWithKind(LeftJoin, "w", "", nil, []string{"a"}),
+	}
+	groupBy := []GroupBySelect{*NewGroupBySelect("", "a")}
+	orderBy := []OrderBySelect{*NewOrderBySelect(a, true, NullsLast)}
+
+	return NewSelectTableCommand("t", "", selectors, joins, NewGtCommon(NewIntCommon(1), a), groupBy,
+		NewGtCommon(NewIntCommon(2), NewAggregateCommon("COUNT", nil, false)), orderBy, 10, true, 5, true)
+}
+
+//sampleExpressions returns an expression of every node type.
+func sampleExpressions() []Expression {
+	a, b := NewIdCommon("a", ""), NewIdCommon("t", "b")
+
+	return []Expression{
+		a,
+		b,
+		NewIntCommon(-42),
+		NewFloatCommon(2.5),
+		NewBoolCommon(true),
+		NewStringCommon("it's"),
+		NewNullCommon(),
+		NewTrueCommon(),
+		NewFalseCommon(),
+		NewSumCommon(b, a),
+		NewSubCommon(b, a),
+		NewMultCommon(b, a),
+		NewDivCommon(b, a),
+		NewEqCommon(b, a),
+		NewNeCommon(b, a),
+		NewLtCommon(b, a),
+		NewGtCommon(b, a),
+		NewLteCommon(b, a),
+		NewGteCommon(b, a),
+		NewAndCommon(NewTrueCommon(), NewEqCommon(b, a)),
+		NewOrCommon(NewFalseCommon(), NewEqCommon(b, a)),
+		NewNotCommon(NewIsNullCommon(a, false)),
+		NewLikeCommon(NewStringCommon("x%"), a),
+		NewLikeEscapeCommon(NewStringCommon("x!%"), a, '!', true),
+		NewBetweenCommon(a, NewIntCommon(1), NewIntCommon(10), true, true),
+		NewInCommon(a, []Expression{NewIntCommon(1), NewStringCommon("x")}, true),
+		NewInSelectCommon(a, sampleQuery(), false),
+		NewExistsCommon(sampleQuery(), true),
+		NewScalarSubqueryCommon(sampleQuery()),
+		NewIsNullCommon(a, true),
+		NewIsBoolCommon(a, false, true),
+		NewIsDistinctFromCommon(b, a, true),
+		NewCaseCommon(nil, []*WhenCommon{NewWhenCommon(NewEqCommon(NewIntCommon(1), a), NewIntCommon(1))}, NewFloatCommon(2)),
+		NewCaseCommon(a, []*WhenCommon{NewWhenCommon(NewIntCommon(1), NewStringCommon("one"))}, nil),
+		NewFuncCallCommon("coalesce", []Expression{a, NewIntCommon(0)}),
+		NewAggregateCommon("sum", a, true),
+		NewAggregateCommon("count", nil, false),
+	}
+}
+
+//sampleCommands returns a command of every instruction type, inserting a value of every literal kind.
+func sampleCommands() []Command {
+	definers := TableColumnDefiners{
+		NewIntegerTableColumn("id", nil, false, true, true, false),
+		NewFloatTableColumn("f", 1.5, true, false, false, false),
+		NewBooleanTableColumn("b", true, true, false, false, false),
+		NewDatetimeTableColumn("d", nil, true, false, false, false),
+		NewCharTableColumn("c", "x", true, false, false, false, 20),
+	}
+	values := map[string]interface{}{
+		"id": int64(1),
+		"f":  2.5,
+		"b":  false,
+		"c":  "it's",
+		"d":  time.Date(2017, 3, 1, 12, 30, 0, 0, time.FixedZone("UTC-6", -6*60*60)),
+		"n":  nil,
+		"e":  NewSumCommon(NewIntCommon(1), NewIntCommon(2)),
+	}
+	assignments := []*AssignmentCommon{NewAssignmentCommon("f", NewMultCommon(NewIntCommon(2), NewIdCommon("f", "")))}
+
+	return []Command{
+		NewCommand(NewCreateTableCommand("t", definers), Create, nil),
+		NewCommand(sampleQuery(), Select, nil),
+		NewCommand(NewCompoundSelectCommand(sampleQuery(), sampleQuery(), Except, true), CompoundSelect, nil),
+		NewCommand(NewInsertCommand("t", values), Insert, nil),
+		NewCommand(NewUpdateTableCommand("t", assignments, NewIsNullCommon(NewIdCommon("b", ""), true)), Update, nil),
+		NewCommand(NewDeleteTableCommand("t", "x", NewEqCommon(NewIntCommon(1), NewIdCommon("x", "id"))), Delete, nil),
+		NewCommand(NewDropCommand("t"), Drop, nil),
+		NewCommand(NewAlterCommand("t", NewAlterDropInst("f")), Alter, nil),
+		NewCommand(NewAlterCommand("t", NewAlterAddInst(definers[1])), Alter, nil),
+		NewCommand(NewAlterCommand("t", NewAlterModifyInst(definers[4])), Alter, nil),
+	}
+}
+
+func TestExpressionJSONRoundTrip(t *testing.T) {
+	for _, expression := range sampleExpressions() {
+		data, err := MarshalExpression(expression)
+		if err != nil {
+			t.Fatalf("%v: %v", expression, err)
+		}
+
+		decoded, err := UnmarshalExpression(data)
+		if err != nil {
+			t.Fatalf("%v: %v", expression, err)
+		}
+
+		if !reflect.DeepEqual(decoded, expression) {
+			t.Errorf("%s decoded as %v", data, decoded)
+		}
+	}
+}
+
+func TestCommandJSONRoundTrip(t *testing.T) {
+	for _, command := range sampleCommands() {
+		data, err := json.Marshal(command)
+		if err != nil {
+			t.Fatalf("%v: %v", command, err)
+		}
+
+		var decoded Command
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("%s: %v", data, err)
+		}
+
+		if decoded.InstructionType != command.InstructionType || decoded.String() != command.String() {
+			t.Errorf("%v decoded as %v", command, decoded)
+		}
+	}
+}
+
+func TestNonFiniteFloatJSON(t *testing.T) {
+	tests := []struct {
+		value   float64
+		encoded string
+	}{
+		{math.NaN(), `"NaN"`},
+		{math.Inf(1), `"Infinity"`},
+		{math.Inf(-1), `"-Infinity"`},
+		{2.5, `2.5`},
+	}
+
+	same := func(a, b float64) bool {
+		return a == b || math.IsNaN(a) && math.IsNaN(b)
+	}
+
+	for _, test := range tests {
+		data, err := MarshalExpression(NewFloatCommon(test.value))
+		if err != nil {
+			t.Fatalf("%v: %v", test.value, err)
+		}
+		if expected := `{"type":"float","value":` + test.encoded + `}`; string(data) != expected {
+			t.Errorf("%v: expected %s, got %s", test.value, expected, data)
+		}
+
+		decoded, err := UnmarshalExpression(data)
+		if err != nil {
+			t.Fatalf("%s: %v", data, err)
+		}
+		if value := decoded.(*FloatCommon).value; !same(value, test.value) {
+			t.Errorf("%s decoded as %v", data, value)
+		}
+
+		command := NewCommand(NewInsertCommand("t", map[string]interface{}{"f": test.value}), Insert, nil)
+		if data, err = json.Marshal(command); err != nil {
+			t.Fatalf("%v: %v", test.value, err)
+		}
+
+		var decodedCommand Command
+		if err := json.Unmarshal(data, &decodedCommand); err != nil {
+			t.Fatalf("%s: %v", data, err)
+		}
+		if value := decodedCommand.tableModifier.(*InsertCommand).Values()["f"]; !same(value.(float64), test.value) {
+			t.Errorf("%s decoded as %v", data, value)
+		}
+	}
+
+	if _, err := UnmarshalExpression([]byte(`{"type":"float","value":"Infinite"}`)); err == nil {
+		t.Errorf("expected an error decoding an unknown float name")
+	}
+}
+
+func TestUnmarshalExpressionMissingOperands(t *testing.T) {
+	tests := []string{
+		`{"type":"sum"}`,
+		`{"type":"sum","left":{"type":"int","value":1}}`,
+		`{"type":"eq","left":null,"right":{"type":"int","value":1}}`,
+		`{"type":"not"}`,
+		`{"type":"not","operand":null}`,
+		`{"type":"between","operand":{"type":"id","name":"a"},"lower":{"type":"int","value":1}}`,
+		`{"type":"in","operand":{"type":"id","name":"a"},"list":[null]}`,
+		`{"type":"in","list":[{"type":"int","value":1}]}`,
+		`{"type":"inSelect","operand":{"type":"id","name":"a"}}`,
+		`{"type":"exists"}`,
+		`{"type":"scalarSubquery"}`,
+		`{"type":"isNull"}`,
+		`{"type":"isBool"}`,
+		`{"type":"isDistinctFrom","right":{"type":"int","value":1}}`,
+		`{"type":"case"}`,
+		`{"type":"case","whens":[{"condition":{"type":"true"}}]}`,
+		`{"type":"funcCall","name":"UPPER","list":[null]}`,
+		`{"type":"like","left":{"type":"id","name":"a"}}`,
+	}
+
+	for _, data := range tests {
+		expression, err := UnmarshalExpression([]byte(data))
+		if _, ok := err.(MissingOperandError); !ok {
+			t.Errorf("%s: expected a MissingOperandError, got %v, %v", data, fmt.Sprint(expression), err)
+		}
+	}
+}
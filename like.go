@@ -0,0 +1,52 @@
+package common
+
+import (
+	"regexp"
+	"strings"
+)
+
+//compileLikePattern translates a LIKE pattern into an anchored regular expression.
+//An escape of zero disables escaping.
+func compileLikePattern(pattern string, escape rune, caseInsensitive bool) (*regexp.Regexp, error) {
+	var builder strings.Builder
+
+	builder.WriteString("(?s)")
+	if caseInsensitive {
+		builder.WriteString("(?i)")
+	}
+	builder.WriteString("^")
+
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			builder.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case escape != 0 && r == escape:
+			escaped = true
+		case r == '%':
+			builder.WriteString(".*")
+		case r == '_':
+			builder.WriteString(".")
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+
+	if escaped {
+		return nil, LikePatternError{pattern, "pattern must not end with the escape character"}
+	}
+
+	builder.WriteString("$")
+	return regexp.Compile(builder.String())
+}
+
+func constantString(expression Expression) (string, bool) {
+	switch s := expression.(type) {
+	case *StringCommon:
+		return s.value, true
+	case StringCommon:
+		return s.value, true
+	}
+	return "", false
+}
@@ -0,0 +1,82 @@
+package common
+
+import "testing"
+
+func TestLikeMatching(t *testing.T) {
+	tests := []struct {
+		value, pattern  string
+		escape          rune
+		caseInsensitive bool
+		expected        bool
+	}{
+		{"modest", "modest", 0, false, true},
+		{"modest", "mod%", 0, false, true},
+		{"modest", "%est", 0, false, true},
+		{"modest", "%", 0, false, true},
+		{"", "%", 0, false, true},
+		{"modest", "m_dest", 0, false, true},
+		{"modest", "m_est", 0, false, false},
+		{"modest", "mod", 0, false, false},
+		{"a.c", "a.c", 0, false, true},
+		{"abc", "a.c", 0, false, false},
+		{"a(b)*", "a(b)*", 0, false, true},
+		{"line\nbreak", "line%", 0, false, true},
+		{"line\nbreak", "line_break", 0, false, true},
+		{"100%", `100\%`, '\\', false, true},
+		{"1000", `100\%`, '\\', false, false},
+		{"a_b", "a!_b", '!', false, true},
+		{"axb", "a!_b", '!', false, false},
+		{`a\b`, `a\b`, 0, false, true},
+		{"a!b", "a!!b", '!', false, true},
+		{"MoDeSt", "modest", 0, false, false},
+		{"MoDeSt", "modest", 0, true, true},
+		{"MoDeSt", "MOD%", 0, true, true},
+		{"ÁRBOL", "árbol", 0, true, true},
+	}
+
+	for _, test := range tests {
+		like := NewLikeEscapeCommon(NewStringCommon(test.pattern), NewStringCommon(test.value), test.escape, test.caseInsensitive)
+
+		value, err := like.EvaluateE(nil)
+		if err != nil || value != test.expected {
+			t.Errorf("%q %s %q: expected %v, got %v (%v)", test.value, like.operator(), test.pattern, test.expected, value, err)
+		}
+	}
+}
+
+func TestLikeWithPatternFromSymbols(t *testing.T) {
+	like := NewLikeCommon(NewIdCommon("pattern", ""), NewIdCommon("value", ""))
+
+	tests := []struct {
+		symbols  map[string]interface{}
+		expected interface{}
+	}{
+		{map[string]interface{}{"value": "modest", "pattern": "mo%"}, true},
+		{map[string]interface{}{"value": "modest", "pattern": "sql%"}, false},
+		{map[string]interface{}{"value": nil, "pattern": "mo%"}, nil},
+		{map[string]interface{}{"value": "modest", "pattern": nil}, nil},
+	}
+
+	for _, test := range tests {
+		value, err := like.EvaluateE(test.symbols)
+		if err != nil || value != test.expected {
+			t.Errorf("%v: expected %v, got %v (%v)", test.symbols, test.expected, value, err)
+		}
+	}
+
+	if _, err := like.EvaluateE(map[string]interface{}{"value": int64(1), "pattern": "1"}); err == nil {
+		t.Error("expected an error for a non string value")
+	}
+}
+
+func TestLikeRejectsTrailingEscape(t *testing.T) {
+	constant := NewLikeEscapeCommon(NewStringCommon(`abc\`), NewStringCommon("abc"), '\\', false)
+	dynamic := NewLikeEscapeCommon(NewIdCommon("pattern", ""), NewStringCommon("abc"), '\\', false)
+
+	for _, like := range []*LikeCommon{constant, dynamic} {
+		_, err := like.EvaluateE(map[string]interface{}{"pattern": `abc\`})
+		if _, ok := err.(LikePatternError); !ok {
+			t.Errorf("expected a LikePatternError, got %v", err)
+		}
+	}
+}
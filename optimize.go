@@ -0,0 +1,148 @@
+package common
+
+//Optimize returns an equivalent expression where constant subtrees are folded into literals, boolean
+//identities such as `x AND TRUE' and `NOT NOT x' are simplified, and comparisons between a constant and
+//a column are written with the column on the left. The input expression is not modified.
+func Optimize(expression Expression) Expression {
+	if expression == nil {
+		return nil
+	}
+
+	operands := expression.Children()
+	if len(operands) == 0 {
+		return expression
+	}
+
+	optimized := make([]Expression, len(operands))
+	for i, operand := range operands {
+		optimized[i] = Optimize(operand)
+	}
+	expression = withChildren(expression, optimized)
+
+	if folded, ok := fold(expression, optimized); ok {
+		return folded
+	}
+
+	return simplify(expression)
+}
+
+//fold evaluates an expression whose operands are all constants. Expressions that fail to evaluate
+//are kept so the error is reported when the query runs.
+func fold(expression Expression, operands []Expression) (Expression, bool) {
+	switch e := expression.(type) {
+	case *AggregateCommon, *InSelectCommon:
+		return nil, false
+	case *FuncCallCommon:
+		if !e.Deterministic() {
+			return nil, false
+		}
+	}
+
+	for _, operand := range operands {
+		if _, ok := constantValue(operand); !ok {
+			return nil, false
+		}
+	}
+
+	value, err := expression.EvaluateE(map[string]interface{}{})
+	if err != nil {
+		return nil, false
+	}
+	return literal(value)
+}
+
+//literal returns the literal expression evaluating to value.
+func literal(value interface{}) (Expression, bool) {
+	switch v := value.(type) {
+	case nil:
+		return NewNullCommon(), true
+	case int64:
+		return NewIntCommon(v), true
+	case float64:
+		return NewFloatCommon(v), true
+	case string:
+		return NewStringCommon(v), true
+	case bool:
+		if v {
+			return NewTrueCommon(), true
+		}
+		return NewFalseCommon(), true
+	}
+	return nil, false
+}
+
+func constantTruth(expression Expression) (truth bool, ok bool) {
+	value, ok := constantValue(expression)
+	if !ok {
+		return false, false
+	}
+	truth, ok = value.(bool)
+	return truth, ok
+}
+
+func simplify(expression Expression) Expression {
+	switch e := expression.(type) {
+	case *AndCommon:
+		return simplifyLogical(e.leftValue, e.rightValue, false)
+	case *OrCommon:
+		return simplifyLogical(e.leftValue, e.rightValue, true)
+	case *NotCommon:
+		if inner, ok := e.not.(*NotCommon); ok {
+			return inner.not
+		}
+	case *EqCommon:
+		if isColumnOnRight(e.leftValue, e.rightValue) {
+			return NewEqCommon(e.leftValue, e.rightValue)
+		}
+	case *NeCommon:
+		if isColumnOnRight(e.leftValue, e.rightValue) {
+			return NewNeCommon(e.leftValue, e.rightValue)
+		}
+	case *LtCommon:
+		if isColumnOnRight(e.leftValue, e.rightValue) {
+			return NewGtCommon(e.leftValue, e.rightValue)
+		}
+	case *GtCommon:
+		if isColumnOnRight(e.leftValue, e.rightValue) {
+			return NewLtCommon(e.leftValue, e.rightValue)
+		}
+	case *LteCommon:
+		if isColumnOnRight(e.leftValue, e.rightValue) {
+			return NewGteCommon(e.leftValue, e.rightValue)
+		}
+	case *GteCommon:
+		if isColumnOnRight(e.leftValue, e.rightValue) {
+			return NewLteCommon(e.leftValue, e.rightValue)
+		}
+	}
+	return expression
+}
+
+//simplifyLogical simplifies AND and OR, where dominant is the constant deciding the result on its own
+//and the other constant is the identity of the operator.
+func simplifyLogical(left Expression, right Expression, dominant bool) Expression {
+	for _, operands := range [][2]Expression{{left, right}, {right, left}} {
+		truth, ok := constantTruth(operands[0])
+		switch {
+		case !ok:
+		case truth == dominant:
+			return operands[0]
+		default:
+			return operands[1]
+		}
+	}
+
+	if dominant {
+		return NewOrCommon(right, left)
+	}
+	return NewAndCommon(right, left)
+}
+
+//isColumnOnRight returns true if a comparison has a constant on the left and a column on the right.
+//The constructors of binary expressions take the right operand first, so passing both operands
+//in the same order to a constructor swaps them.
+func isColumnOnRight(left Expression, right Expression) bool {
+	_, constant := constantValue(left)
+	_, column := right.(*IdCommon)
+	return constant && column
+}
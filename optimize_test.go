@@ -0,0 +1,97 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	x, y := NewIdCommon("x", ""), NewIdCommon("y", "")
+	one, two := NewIntCommon(1), NewIntCommon(2)
+
+	tests := []struct {
+		expression Expression
+		expected   string
+	}{
+		{NewSumCommon(NewMultCommon(NewIntCommon(3), two), one), "7"},
+		{NewSumCommon(NewSumCommon(two, one), x), "x + 3"},
+		{NewEqCommon(one, NewNullCommon()), "NULL"},
+		{NewDivCommon(NewIntCommon(0), one), "1 / 0"},
+		{NewFuncCallCommon("upper", []Expression{NewStringCommon("a")}), "'A'"},
+		{NewInCommon(two, []Expression{one, two}, false), "TRUE"},
+		{NewAndCommon(NewTrueCommon(), x), "x"},
+		{NewAndCommon(x, NewTrueCommon()), "x"},
+		{NewAndCommon(NewFalseCommon(), x), "FALSE"},
+		{NewOrCommon(NewTrueCommon(), x), "TRUE"},
+		{NewOrCommon(x, NewFalseCommon()), "x"},
+		{NewAndCommon(NewNullCommon(), x), "x AND NULL"},
+		{NewAndCommon(y, x), "x AND y"},
+		{NewNotCommon(NewNotCommon(x)), "x"},
+		{NewNotCommon(NewNotCommon(NewNotCommon(x))), "NOT x"},
+		{NewAndCommon(NewEqCommon(NewSumCommon(one, one), x), NewNotCommon(NewFalseCommon())), "x = 2"},
+		{NewEqCommon(x, one), "x = 1"},
+		{NewNeCommon(x, one), "x <> 1"},
+		{NewLtCommon(x, one), "x > 1"},
+		{NewGtCommon(x, one), "x < 1"},
+		{NewLteCommon(x, one), "x >= 1"},
+		{NewGteCommon(x, one), "x <= 1"},
+		{NewLtCommon(one, x), "x < 1"},
+		{NewLtCommon(y, x), "x < y"},
+		{NewGtCommon(NewIntCommon(0), NewAggregateCommon("count", nil, false)), "COUNT(*) > 0"},
+	}
+
+	for _, test := range tests {
+		original := fmt.Sprint(test.expression)
+
+		if optimized := fmt.Sprint(Optimize(test.expression)); optimized != test.expected {
+			t.Errorf("%s: expected %s, got %s", original, test.expected, optimized)
+		}
+		if fmt.Sprint(test.expression) != original {
+			t.Errorf("%s: the input was modified into %v", original, test.expression)
+		}
+	}
+}
+
+func TestOptimizeNil(t *testing.T) {
+	if optimized := Optimize(nil); optimized != nil {
+		t.Errorf("expected nil, got %v", optimized)
+	}
+}
+
+func TestOptimizePreservesResults(t *testing.T) {
+	x := NewIdCommon("x", "")
+	expressions := []Expression{
+		NewOrCommon(NewLtCommon(NewIntCommon(10), x), NewAndCommon(NewTrueCommon(), NewEqCommon(x, NewSumCommon(NewIntCommon(1), NewIntCommon(1))))),
+		NewNotCommon(NewNotCommon(NewGteCommon(x, NewFloatCommon(2.5)))),
+		NewCaseCommon(nil, []*WhenCommon{NewWhenCommon(NewLteCommon(x, NewIntCommon(0)), NewMultCommon(x, NewIntCommon(2)))}, NewFloatCommon(0.5)),
+		NewBetweenCommon(x, NewSubCommon(NewIntCommon(1), NewIntCommon(3)), NewIntCommon(5), false, true),
+	}
+
+	for _, expression := range expressions {
+		optimized := Optimize(expression)
+
+		for _, value := range []interface{}{nil, int64(-1), int64(0), int64(2), 2.5, int64(11)} {
+			symbols := map[string]interface{}{"x": value}
+
+			expected, expectedErr := expression.EvaluateE(symbols)
+			result, err := optimized.EvaluateE(symbols)
+			if result != expected || (err == nil) != (expectedErr == nil) {
+				t.Errorf("%v optimized into %v with x = %v: expected %v (%v), got %v (%v)", expression, optimized, value, expected, expectedErr, result, err)
+			}
+		}
+	}
+}
+
+func TestOptimizeKeepsNonDeterministicCalls(t *testing.T) {
+	functions := NewFunctionRegistry()
+	if err := functions.Register(ScalarFunction{"RANDOM", 0, 0, false, false, func([]interface{}) (interface{}, error) {
+		return 4.0, nil
+	}, returns(FloatType)}); err != nil {
+		t.Fatal(err)
+	}
+
+	call := Bind(NewFuncCallCommon("abs", []Expression{NewFuncCallCommon("random", nil)}), Environment{Functions: functions})
+	if optimized := fmt.Sprint(Optimize(call)); optimized != "ABS(RANDOM())" {
+		t.Errorf("expected the call to be kept, got %s", optimized)
+	}
+}
@@ -0,0 +1,222 @@
+package common
+
+import (
+	"container/heap"
+	"sort"
+)
+
+//NullsOrder determines where NULL values are placed when sorting.
+type NullsOrder int
+
+//Null ordering constants. By default NULL values sort as if they were greater than any other value.
+const (
+	NullsDefault NullsOrder = iota
+	NullsFirst
+	NullsLast
+)
+
+//OrderBySelect represents a sort key in the ORDER BY clause of a select query.
+type OrderBySelect struct {
+	expression Expression
+	descending bool
+	nulls      NullsOrder
+}
+
+//NewOrderBySelect creates an instance of OrderBySelect.
+func NewOrderBySelect(expression Expression, descending bool, nulls NullsOrder) *OrderBySelect {
+	return &OrderBySelect{expression, descending, nulls}
+}
+
+//Expression returns the expression rows are sorted by.
+func (o OrderBySelect) Expression() Expression {
+	return o.expression
+}
+
+//Descending returns true if rows are sorted in descending order.
+func (o OrderBySelect) Descending() bool {
+	return o.descending
+}
+
+//Nulls returns where NULL values are placed.
+func (o OrderBySelect) Nulls() NullsOrder {
+	return o.nulls
+}
+
+func (o OrderBySelect) nullsFirst() bool {
+	switch o.nulls {
+	case NullsFirst:
+		return true
+	case NullsLast:
+		return false
+	}
+	return o.descending
+}
+
+//compare compares two values of the sort key, returning a negative number when a sorts before b.
+func (o OrderBySelect) compare(a interface{}, b interface{}) (int, error) {
+	switch {
+	case a == nil && b == nil:
+		return 0, nil
+	case a == nil:
+		if o.nullsFirst() {
+			return -1, nil
+		}
+		return 1, nil
+	case b == nil:
+		if o.nullsFirst() {
+			return 1, nil
+		}
+		return -1, nil
+	}
+
+	result, err := compareValues("ORDER BY", a, b)
+	if o.descending {
+		result = -result
+	}
+	return result, err
+}
+
+//CompareRows compares two rows by the given sort keys, using the same numeric promotion as LtCommon.
+func CompareRows(orderBy []OrderBySelect, a map[string]interface{}, b map[string]interface{}) (int, error) {
+	aKeys, err := sortKeys(orderBy, a)
+	if err != nil {
+		return 0, err
+	}
+
+	bKeys, err := sortKeys(orderBy, b)
+	if err != nil {
+		return 0, err
+	}
+
+	return compareSortKeys(orderBy, aKeys, bKeys)
+}
+
+func sortKeys(orderBy []OrderBySelect, row map[string]interface{}) ([]interface{}, error) {
+	keys := make([]interface{}, len(orderBy))
+
+	for i, order := range orderBy {
+		value, err := order.expression.EvaluateE(row)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = value
+	}
+
+	return keys, nil
+}
+
+func compareSortKeys(orderBy []OrderBySelect, a []interface{}, b []interface{}) (int, error) {
+	for i, order := range orderBy {
+		result, err := order.compare(a[i], b[i])
+		if err != nil || result != 0 {
+			return result, err
+		}
+	}
+	return 0, nil
+}
+
+type sortedRow struct {
+	row   map[string]interface{}
+	keys  []interface{}
+	index int
+}
+
+//sortedRows orders rows by their sort keys, falling back to their original position so sorting is stable.
+type sortedRows struct {
+	orderBy []OrderBySelect
+	rows    []sortedRow
+	reverse bool
+	err     error
+}
+
+func (s *sortedRows) Len() int {
+	return len(s.rows)
+}
+
+func (s *sortedRows) Less(i, j int) bool {
+	result, err := compareSortKeys(s.orderBy, s.rows[i].keys, s.rows[j].keys)
+	if err != nil && s.err == nil {
+		s.err = err
+	}
+
+	if result == 0 {
+		result = s.rows[i].index - s.rows[j].index
+	}
+
+	if s.reverse {
+		return result > 0
+	}
+	return result < 0
+}
+
+func (s *sortedRows) Swap(i, j int) {
+	s.rows[i], s.rows[j] = s.rows[j], s.rows[i]
+}
+
+func (s *sortedRows) Push(x interface{}) {
+	s.rows = append(s.rows, x.(sortedRow))
+}
+
+func (s *sortedRows) Pop() interface{} {
+	last := s.rows[len(s.rows)-1]
+	s.rows = s.rows[:len(s.rows)-1]
+	return last
+}
+
+//SortRows returns rows sorted by the ORDER BY keys, skipping offset rows and keeping at most limit rows when
+//hasLimit is set. When the limit is smaller than the number of rows only the first limit + offset rows are
+//kept in a heap instead of sorting every row. A negative offset or limit is an error.
+func SortRows(rows []map[string]interface{}, orderBy []OrderBySelect, limit int64, hasLimit bool, offset int64) ([]map[string]interface{}, error) {
+	if hasLimit && limit < 0 {
+		return nil, NegativeRowCountError{"LIMIT", limit}
+	}
+	if offset < 0 {
+		return nil, NegativeRowCountError{"OFFSET", offset}
+	}
+
+	keep := int64(len(rows))
+	if hasLimit && limit < keep && offset < keep-limit {
+		keep = limit + offset
+	}
+
+	sorted := &sortedRows{orderBy: orderBy}
+
+	if keep < int64(len(rows)) {
+		sorted.reverse = true
+		for i, row := range rows {
+			keys, err := sortKeys(orderBy, row)
+			if err != nil {
+				return nil, err
+			}
+
+			heap.Push(sorted, sortedRow{row, keys, i})
+			if int64(sorted.Len()) > keep {
+				heap.Pop(sorted)
+			}
+		}
+		sorted.reverse = false
+	} else {
+		for i, row := range rows {
+			keys, err := sortKeys(orderBy, row)
+			if err != nil {
+				return nil, err
+			}
+			sorted.rows = append(sorted.rows, sortedRow{row, keys, i})
+		}
+	}
+
+	sort.Sort(sorted)
+	if sorted.err != nil {
+		return nil, sorted.err
+	}
+
+	result := []map[string]interface{}{}
+	for i := offset; i < int64(len(sorted.rows)); i++ {
+		if hasLimit && int64(len(result)) >= limit {
+			break
+		}
+		result = append(result, sorted.rows[i].row)
+	}
+
+	return result, nil
+}
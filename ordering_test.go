@@ -0,0 +1,259 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func sortingRows(values ...interface{}) []map[string]interface{} {
+	rows := make([]map[string]interface{}, len(values))
+	for i, value := range values {
+		rows[i] = map[string]interface{}{"a": value}
+	}
+	return rows
+}
+
+func columnValues(rows []map[string]interface{}) []interface{} {
+	values := make([]interface{}, len(rows))
+	for i, row := range rows {
+		values[i] = row["a"]
+	}
+	return values
+}
+
+func TestSortRowsLimitAndOffset(t *testing.T) {
+	orderBy := []OrderBySelect{*NewOrderBySelect(NewIdCommon("a", ""), false, NullsDefault)}
+
+	tests := []struct {
+		limit    int64
+		hasLimit bool
+		offset   int64
+		expected []interface{}
+	}{
+		{0, false, 0, []interface{}{int64(1), int64(2), int64(3), int64(4)}},
+		{2, true, 0, []interface{}{int64(1), int64(2)}},
+		{2, true, 1, []interface{}{int64(2), int64(3)}},
+		{0, true, 0, []interface{}{}},
+		{0, false, 3, []interface{}{int64(4)}},
+		{0, false, 10, []interface{}{}},
+		{math.MaxInt64, true, 1, []interface{}{int64(2), int64(3), int64(4)}},
+		{1, true, math.MaxInt64, []interface{}{}},
+	}
+
+	for _, test := range tests {
+		sorted, err := SortRows(sortingRows(int64(3), int64(1), int64(4), int64(2)), orderBy, test.limit, test.hasLimit, test.offset)
+		if err != nil {
+			t.Errorf("LIMIT %d OFFSET %d: unexpected error %v", test.limit, test.offset, err)
+			continue
+		}
+
+		values := columnValues(sorted)
+		if len(values) != len(test.expected) {
+			t.Errorf("LIMIT %d OFFSET %d: expected %v, got %v", test.limit, test.offset, test.expected, values)
+			continue
+		}
+		for i := range values {
+			if values[i] != test.expected[i] {
+				t.Errorf("LIMIT %d OFFSET %d: expected %v, got %v", test.limit, test.offset, test.expected, values)
+				break
+			}
+		}
+	}
+}
+
+func TestSortRowsRejectsNegativeCounts(t *testing.T) {
+	orderBy := []OrderBySelect{*NewOrderBySelect(NewIdCommon("a", ""), false, NullsDefault)}
+
+	tests := []struct {
+		limit    int64
+		hasLimit bool
+		offset   int64
+	}{
+		{-2, true, 0},
+		{0, false, -1},
+		{1, true, -1},
+	}
+
+	for _, test := range tests {
+		if _, err := SortRows(sortingRows(int64(1)), orderBy, test.limit, test.hasLimit, test.offset); err == nil {
+			t.Errorf("LIMIT %d OFFSET %d: expected an error", test.limit, test.offset)
+		}
+	}
+}
+
+func TestSortRowsNullsOrder(t *testing.T) {
+	tests := []struct {
+		descending bool
+		nulls      NullsOrder
+		expected   string
+	}{
+		{false, NullsDefault, "[1 2 3 <nil>]"},
+		{true, NullsDefault, "[<nil> 3 2 1]"},
+		{false, NullsFirst, "[<nil> 1 2 3]"},
+		{true, NullsLast, "[3 2 1 <nil>]"},
+		{false, NullsLast, "[1 2 3 <nil>]"},
+		{true, NullsFirst, "[<nil> 3 2 1]"},
+	}
+
+	for _, test := range tests {
+		orderBy := []OrderBySelect{*NewOrderBySelect(NewIdCommon("a", ""), test.descending, test.nulls)}
+
+		const limit = 3
+		for _, hasLimit := range []bool{false, true} {
+			sorted, err := SortRows(sortingRows(int64(2), nil, 3.0, int64(1)), orderBy, limit, hasLimit, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := test.expected
+			if hasLimit {
+				expected = fmt.Sprint(strings.Fields(strings.Trim(expected, "[]"))[:limit])
+			}
+			if values := fmt.Sprint(columnValues(sorted)); values != expected {
+				t.Errorf("descending %v nulls %d limited %v: expected %s, got %s", test.descending, test.nulls, hasLimit, expected, values)
+			}
+		}
+	}
+}
+
+func TestSortRowsIsStable(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"a": int64(2), "b": "first"},
+		{"a": int64(1), "b": "x"},
+		{"a": int64(2), "b": "second"},
+		{"a": int64(1), "b": "y"},
+		{"a": int64(2), "b": "third"},
+	}
+	orderBy := []OrderBySelect{*NewOrderBySelect(NewIdCommon("a", ""), true, NullsDefault)}
+
+	limits := []struct {
+		limit    int64
+		hasLimit bool
+	}{{0, false}, {4, true}, {2, true}}
+
+	for _, test := range limits {
+		limit, hasLimit := test.limit, test.hasLimit
+		sorted, err := SortRows(rows, orderBy, limit, hasLimit, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names := []string{}
+		for _, row := range sorted {
+			names = append(names, row["b"].(string))
+		}
+
+		expected := []string{"second", "third", "x", "y"}
+		if hasLimit {
+			expected = expected[:limit]
+		}
+		if fmt.Sprint(names) != fmt.Sprint(expected) {
+			t.Errorf("limit %d: expected %v, got %v", limit, expected, names)
+		}
+	}
+}
+
+func TestCompareRows(t *testing.T) {
+	orderBy := []OrderBySelect{
+		*NewOrderBySelect(NewIdCommon("a", ""), false, NullsDefault),
+		*NewOrderBySelect(NewIdCommon("b", ""), true, NullsDefault),
+	}
+
+	tests := []struct {
+		a, b     map[string]interface{}
+		expected int
+	}{
+		{map[string]interface{}{"a": int64(1), "b": "x"}, map[string]interface{}{"a": 2.5, "b": "x"}, -1},
+		{map[string]interface{}{"a": int64(2), "b": "x"}, map[string]interface{}{"a": 2.0, "b": "y"}, 1},
+		{map[string]interface{}{"a": int64(2), "b": "y"}, map[string]interface{}{"a": int64(2), "b": "y"}, 0},
+		{map[string]interface{}{"a": nil, "b": "y"}, map[string]interface{}{"a": int64(2), "b": "y"}, 1},
+	}
+
+	for _, test := range tests {
+		if result, err := CompareRows(orderBy, test.a, test.b); err != nil || result != test.expected {
+			t.Errorf("%v, %v: expected %d, got %d (%v)", test.a, test.b, test.expected, result, err)
+		}
+	}
+
+	if _, err := CompareRows(orderBy, map[string]interface{}{"a": int64(1)}, map[string]interface{}{"a": "1"}); err == nil {
+		t.Error("expected an error comparing an integer with a string")
+	}
+	if _, err := SortRows(sortingRows(int64(1), "a"), orderBy[:1], 0, false, 0); err == nil {
+		t.Error("expected an error sorting an integer with a string")
+	}
+}
+
+func TestSelectLimit(t *testing.T) {
+	selectors := TableColumnSelectors{NewTableColumnSelector(false, "", "a", "", nil)}
+	tests := []struct {
+		query    *SelectTableCommand
+		hasLimit bool
+		sql      string
+	}{
+		{NewSelectTableCommand("t", "", selectors, nil, nil, nil, nil, nil, 0, false, 0, false), false, "SELECT a FROM t"},
+		{NewSelectTableCommand("t", "", selectors, nil, nil, nil, nil, nil, 0, true, 0, false), true, "SELECT a FROM t LIMIT 0"},
+		{NewSelectTableCommand("t", "", selectors, nil, nil, nil, nil, nil, 3, true, 1, false), true, "SELECT a FROM t LIMIT 3 OFFSET 1"},
+	}
+
+	for _, test := range tests {
+		if sql := test.query.String(); sql != test.sql {
+			t.Errorf("expected %s, got %s", test.sql, sql)
+		}
+
+		command := NewCommand(test.query, Select, nil)
+		data, err := json.Marshal(command)
+		if err != nil {
+			t.Fatal(err)
+		}
+		binary, err := command.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var fromJSON, fromBinary Command
+		if err := json.Unmarshal(data, &fromJSON); err != nil {
+			t.Fatal(err)
+		}
+		if err := fromBinary.UnmarshalBinary(binary); err != nil {
+			t.Fatal(err)
+		}
+
+		limit, _ := test.query.Limit()
+		for _, decoded := range []Command{fromJSON, fromBinary} {
+			decodedLimit, hasLimit := decoded.tableModifier.(*SelectTableCommand).Limit()
+			if decodedLimit != limit || hasLimit != test.hasLimit {
+				t.Errorf("%s: decoded LIMIT %d (%v)", test.sql, decodedLimit, hasLimit)
+			}
+		}
+	}
+}
+
+func TestBooleansOrderFalseFirst(t *testing.T) {
+	orderBy := []OrderBySelect{*NewOrderBySelect(NewIdCommon("a", ""), false, NullsDefault)}
+
+	sorted, err := SortRows(sortingRows(true, nil, false, true), orderBy, 0, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values := fmt.Sprint(columnValues(sorted)); values != "[false true true <nil>]" {
+		t.Errorf("expected [false true true <nil>], got %s", values)
+	}
+
+	for name, expected := range map[string]bool{"MIN": false, "MAX": true} {
+		aggregate, err := DefaultAggregates.New(name, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, value := range []interface{}{true, nil, false} {
+			if err := aggregate.Accumulate(value); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+		}
+		if result := aggregate.Result(); result != expected {
+			t.Errorf("%s: expected %v, got %v", name, expected, result)
+		}
+	}
+}
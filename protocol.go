@@ -0,0 +1,137 @@
+package common
+
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+)
+
+//MessageType is used to determine the kind of a Message.
+type MessageType int
+
+//Message type constants.
+const (
+	QueryMessage MessageType = iota
+	ResultHeaderMessage
+	RowBatchMessage
+	ErrorMessage
+	AckMessage
+	CloseMessage
+	ResultTrailerMessage
+)
+
+var messageTypeName = map[MessageType]string{
+	QueryMessage:         "QUERY",
+	ResultHeaderMessage:  "RESULT HEADER",
+	RowBatchMessage:      "ROW BATCH",
+	ErrorMessage:         "ERROR",
+	AckMessage:           "ACK",
+	CloseMessage:         "CLOSE",
+	ResultTrailerMessage: "RESULT TRAILER",
+}
+
+func (t MessageType) String() string {
+	return messageTypeName[t]
+}
+
+//MaxMessageSize is the largest message data accepted by WriteMessage and ReadMessage.
+const MaxMessageSize = 16 << 20
+
+//messageHeaderSize is the size of a frame header: the length of the data as a big endian uint32
+//followed by the message type in one byte.
+const messageHeaderSize = 5
+
+//Message is the unit exchanged between clients and servers. Data holds the SQL text of a query,
+//the encoded header or rows of a result, or the text of an error.
+type Message struct {
+	Type MessageType `json:"Type"`
+	Data string      `json:"Data"`
+}
+
+//NewMessage creates an instance of Message.
+func NewMessage(messageType MessageType, data string) Message {
+	return Message{messageType, data}
+}
+
+//NewErrorMessage creates an error message holding the text of err.
+func NewErrorMessage(err error) Message {
+	return Message{ErrorMessage, err.Error()}
+}
+
+//WriteMessage writes a framed message to w in a single call to Write.
+func WriteMessage(w io.Writer, message Message) error {
+	if _, ok := messageTypeName[message.Type]; !ok {
+		return UnknownMessageTypeError{message.Type}
+	}
+
+	if len(message.Data) > MaxMessageSize {
+		return MessageSizeError{uint64(len(message.Data))}
+	}
+
+	frame := make([]byte, messageHeaderSize, messageHeaderSize+len(message.Data))
+	binary.BigEndian.PutUint32(frame, uint32(len(message.Data)))
+	frame[4] = byte(message.Type)
+	frame = append(frame, message.Data...)
+
+	_, err := w.Write(frame)
+	return err
+}
+
+//ReadMessage reads a framed message from r. It returns io.EOF when r ends before a message and
+//io.ErrUnexpectedEOF when it ends in the middle of one. After any other error the stream is no
+//longer aligned on a frame and should be closed.
+func ReadMessage(r io.Reader) (Message, error) {
+	var header [messageHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return Message{}, err
+	}
+
+	size := binary.BigEndian.Uint32(header[:4])
+	if size > MaxMessageSize {
+		return Message{}, MessageSizeError{uint64(size)}
+	}
+
+	messageType := MessageType(header[4])
+	if _, ok := messageTypeName[messageType]; !ok {
+		return Message{}, UnknownMessageTypeError{messageType}
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return Message{}, err
+	}
+
+	return Message{messageType, string(data)}, nil
+}
+
+//MessageConn exchanges framed messages over an io.ReadWriter such as a net.Conn. Send and Receive
+//are safe to call from different goroutines.
+type MessageConn struct {
+	readWriter io.ReadWriter
+	readMutex  sync.Mutex
+	writeMutex sync.Mutex
+}
+
+//NewMessageConn creates an instance of MessageConn.
+func NewMessageConn(readWriter io.ReadWriter) *MessageConn {
+	return &MessageConn{readWriter: readWriter}
+}
+
+//Send writes a message.
+func (c *MessageConn) Send(message Message) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	return WriteMessage(c.readWriter, message)
+}
+
+//Receive reads the next message.
+func (c *MessageConn) Receive() (Message, error) {
+	c.readMutex.Lock()
+	defer c.readMutex.Unlock()
+
+	return ReadMessage(c.readWriter)
+}
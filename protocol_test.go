@@ -0,0 +1,122 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestMessageConnRoundTrip(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	messages := []Message{
+		NewMessage(QueryMessage, "SELECT * FROM t"),
+		NewMessage(AckMessage, ""),
+		NewErrorMessage(ErrDivisionByZero),
+		NewMessage(RowBatchMessage, strings.Repeat("x", 1<<16)),
+	}
+
+	go func() {
+		conn := NewMessageConn(client)
+		for _, message := range messages {
+			if err := conn.Send(message); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		client.Close()
+	}()
+
+	conn := NewMessageConn(server)
+	for _, expected := range messages {
+		message, err := conn.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if message != expected {
+			t.Fatalf("expected %v message of %d bytes, got %v message of %d bytes", expected.Type, len(expected.Data), message.Type, len(message.Data))
+		}
+	}
+
+	if _, err := conn.Receive(); err != io.EOF {
+		t.Errorf("expected io.EOF after the last message, got %v", err)
+	}
+}
+
+func TestMessageSizeLimit(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	large := NewMessage(RowBatchMessage, strings.Repeat("x", MaxMessageSize+1))
+	if err := NewMessageConn(client).Send(large); err == nil {
+		t.Error("expected an error sending a message larger than MaxMessageSize")
+	}
+
+	go func() {
+		var header [messageHeaderSize]byte
+		binary.BigEndian.PutUint32(header[:], MaxMessageSize+1)
+		header[4] = byte(RowBatchMessage)
+		client.Write(header[:])
+	}()
+
+	if _, err := NewMessageConn(server).Receive(); err == nil {
+		t.Error("expected an error receiving a frame larger than MaxMessageSize")
+	} else if _, ok := err.(MessageSizeError); !ok {
+		t.Errorf("expected a MessageSizeError, got %v", err)
+	}
+}
+
+func TestUnknownMessageType(t *testing.T) {
+	if err := WriteMessage(io.Discard, NewMessage(MessageType(200), "")); err == nil {
+		t.Error("expected an error writing an unknown message type")
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go client.Write([]byte{0, 0, 0, 0, 200})
+
+	if _, err := NewMessageConn(server).Receive(); err == nil {
+		t.Error("expected an error receiving an unknown message type")
+	} else if _, ok := err.(UnknownMessageTypeError); !ok {
+		t.Errorf("expected an UnknownMessageTypeError, got %v", err)
+	}
+}
+
+func TestTruncatedFrame(t *testing.T) {
+	var frame bytes.Buffer
+	if err := WriteMessage(&frame, NewMessage(QueryMessage, "SELECT 1")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		length   int
+		expected error
+	}{
+		{0, io.EOF},
+		{2, io.ErrUnexpectedEOF},
+		{messageHeaderSize, io.ErrUnexpectedEOF},
+		{frame.Len() - 1, io.ErrUnexpectedEOF},
+	}
+
+	for _, test := range tests {
+		client, server := net.Pipe()
+
+		go func(data []byte) {
+			client.Write(data)
+			client.Close()
+		}(frame.Bytes()[:test.length])
+
+		if _, err := NewMessageConn(server).Receive(); err != test.expected {
+			t.Errorf("%d of %d bytes: expected %v, got %v", test.length, frame.Len(), test.expected, err)
+		}
+		server.Close()
+	}
+}
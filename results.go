@@ -0,0 +1,412 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+)
+
+//DefaultBatchSize is the number of rows per batch used by a ResultWriter created with a batch size of zero.
+const DefaultBatchSize = 100
+
+//maxBatchBytes bounds the encoded rows of a batch, which is sent early when the next row would not fit.
+const maxBatchBytes = 1 << 20
+
+//ErrResultCancelled is returned by a ResultWriter once the client has cancelled the result set.
+var ErrResultCancelled = errors.New("Result set cancelled by the client")
+
+//ResultStatus is used to determine how a result set ended.
+type ResultStatus int
+
+//Result status constants.
+const (
+	ResultComplete ResultStatus = iota
+	ResultCancelled
+	ResultFailed
+)
+
+var resultStatusName = map[ResultStatus]string{
+	ResultComplete:  "COMPLETE",
+	ResultCancelled: "CANCELLED",
+	ResultFailed:    "FAILED",
+}
+
+func (s ResultStatus) String() string {
+	return resultStatusName[s]
+}
+
+//MarshalText encodes the status by its name.
+func (s ResultStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+//UnmarshalText decodes a status encoded by MarshalText.
+func (s *ResultStatus) UnmarshalText(text []byte) error {
+	for value, name := range resultStatusName {
+		if name == string(text) {
+			*s = value
+			return nil
+		}
+	}
+	return UnknownTypeError{string(text)}
+}
+
+//ResultColumn describes a column of a result set.
+type ResultColumn struct {
+	Name string `json:"Name"`
+	Type Type   `json:"Type"`
+}
+
+//ResultHeader is sent before the rows of a result set.
+type ResultHeader struct {
+	Columns []ResultColumn `json:"Columns"`
+}
+
+//ResultTrailer is sent after the last batch of a result set. Rows is the number of rows sent.
+type ResultTrailer struct {
+	Rows   int64        `json:"Rows"`
+	Status ResultStatus `json:"Status"`
+	Error  string       `json:"Error,omitempty"`
+}
+
+//err returns the error reported by the trailer, or io.EOF when the result set did not fail.
+func (t ResultTrailer) err() error {
+	if t.Status == ResultFailed {
+		return ServerError{t.Error}
+	}
+	return io.EOF
+}
+
+//ResultWriter streams a result set as a header, row batches of bounded size and a trailer. After
+//every batch it waits for the client to acknowledge it, or to close the result set to cancel it,
+//so that a slow client is never sent more than one batch ahead.
+type ResultWriter struct {
+	conn       *MessageConn
+	columns    int
+	batchSize  int
+	batch      [][]byte
+	batchBytes int
+	rows       int64
+	cancelled  bool
+}
+
+//NewResultWriter sends the header of a result set and returns a ResultWriter for its rows.
+func NewResultWriter(conn *MessageConn, columns []ResultColumn, batchSize int) (*ResultWriter, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	header, err := json.Marshal(ResultHeader{columns})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.Send(NewMessage(ResultHeaderMessage, string(header))); err != nil {
+		return nil, err
+	}
+
+	return &ResultWriter{conn: conn, columns: len(columns), batchSize: batchSize}, nil
+}
+
+//WriteRow adds a row to the current batch and sends the batch when it is full. It returns
+//ErrResultCancelled once the client has cancelled the result set.
+func (w *ResultWriter) WriteRow(row []interface{}) error {
+	if w.cancelled {
+		return ErrResultCancelled
+	}
+
+	if len(row) != w.columns {
+		return RowLengthError{w.columns, len(row)}
+	}
+
+	encoded, err := json.Marshal(row)
+	if err != nil {
+		return err
+	}
+
+	if len(w.batch) > 0 && w.batchBytes+len(encoded) > maxBatchBytes {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+
+	w.batch = append(w.batch, encoded)
+	w.batchBytes += len(encoded)
+
+	if len(w.batch) >= w.batchSize {
+		return w.flush()
+	}
+	return nil
+}
+
+//flush sends the current batch and waits for the client to acknowledge or cancel it.
+func (w *ResultWriter) flush() error {
+	if len(w.batch) == 0 {
+		return nil
+	}
+
+	var data bytes.Buffer
+	data.WriteByte('[')
+	data.Write(bytes.Join(w.batch, []byte{','}))
+	data.WriteByte(']')
+
+	if err := w.conn.Send(NewMessage(RowBatchMessage, data.String())); err != nil {
+		return err
+	}
+
+	w.rows += int64(len(w.batch))
+	w.batch, w.batchBytes = w.batch[:0], 0
+
+	message, err := w.conn.Receive()
+	if err != nil {
+		return err
+	}
+
+	switch message.Type {
+	case AckMessage:
+		return nil
+	case CloseMessage:
+		w.cancelled = true
+		return ErrResultCancelled
+	}
+	return UnexpectedMessageError{message.Type}
+}
+
+//Close sends the remaining rows followed by the trailer. When err isn't nil the remaining rows
+//are discarded and the trailer reports err to the client.
+func (w *ResultWriter) Close(err error) error {
+	trailer := ResultTrailer{Status: ResultComplete}
+
+	switch {
+	case w.cancelled:
+		trailer.Status = ResultCancelled
+	case err != nil:
+		trailer.Status, trailer.Error = ResultFailed, err.Error()
+	default:
+		if err := w.flush(); err == ErrResultCancelled {
+			trailer.Status = ResultCancelled
+		} else if err != nil {
+			return err
+		}
+	}
+	trailer.Rows = w.rows
+
+	data, err := json.Marshal(trailer)
+	if err != nil {
+		return err
+	}
+	return w.conn.Send(NewMessage(ResultTrailerMessage, string(data)))
+}
+
+//StreamResult sends the rows returned by rows as a result set, taking the value of each column
+//from the row symbols under the column name. It stops early and returns nil when the client
+//cancels the result set.
+func StreamResult(conn *MessageConn, columns []ResultColumn, rows RowIterator, batchSize int) error {
+	writer, err := NewResultWriter(conn, columns, batchSize)
+	if err != nil {
+		return err
+	}
+
+	for {
+		symbols, err := rows.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if closeErr := writer.Close(err); closeErr != nil {
+				return closeErr
+			}
+			return err
+		}
+
+		row := make([]interface{}, len(columns))
+		for i, column := range columns {
+			row[i] = symbols[column.Name]
+		}
+
+		if err := writer.WriteRow(row); err == ErrResultCancelled {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return writer.Close(nil)
+}
+
+//ResultReader reads a result set streamed by a ResultWriter.
+type ResultReader struct {
+	conn    *MessageConn
+	columns []ResultColumn
+	batch   [][]interface{}
+	waiting bool
+	trailer *ResultTrailer
+}
+
+//NewResultReader reads the header of a result set. It returns a ServerError if the server answered with an error message instead.
+func NewResultReader(conn *MessageConn) (*ResultReader, error) {
+	message, err := conn.Receive()
+	if err != nil {
+		return nil, err
+	}
+
+	switch message.Type {
+	case ResultHeaderMessage:
+	case ErrorMessage:
+		return nil, ServerError{message.Data}
+	default:
+		return nil, UnexpectedMessageError{message.Type}
+	}
+
+	var header ResultHeader
+	if err := json.Unmarshal([]byte(message.Data), &header); err != nil {
+		return nil, err
+	}
+
+	return &ResultReader{conn: conn, columns: header.Columns}, nil
+}
+
+//Columns returns the columns of the result set.
+func (r *ResultReader) Columns() []ResultColumn {
+	return r.columns
+}
+
+//Trailer returns the trailer of the result set and returns true once it has been received.
+func (r *ResultReader) Trailer() (ResultTrailer, bool) {
+	if r.trailer == nil {
+		return ResultTrailer{}, false
+	}
+	return *r.trailer, true
+}
+
+//Next returns the next row, acknowledging the current batch when all of its rows have been read.
+//It returns io.EOF after the last row, or a ServerError if the result set failed.
+func (r *ResultReader) Next() ([]interface{}, error) {
+	for len(r.batch) == 0 {
+		if r.trailer != nil {
+			return nil, r.trailer.err()
+		}
+
+		if err := r.receive(AckMessage); err != nil {
+			return nil, err
+		}
+	}
+
+	row := r.batch[0]
+	r.batch = r.batch[1:]
+	return row, nil
+}
+
+//Cancel asks the server to stop sending rows and waits for the trailer. Rows that have not been read are discarded.
+func (r *ResultReader) Cancel() error {
+	for r.trailer == nil {
+		if err := r.receive(CloseMessage); err != nil {
+			return err
+		}
+	}
+
+	r.batch = nil
+	return nil
+}
+
+//receive answers the last batch with reply when the server is waiting for it, and then reads the next batch or the trailer.
+func (r *ResultReader) receive(reply MessageType) error {
+	if r.waiting {
+		if err := r.conn.Send(NewMessage(reply, "")); err != nil {
+			return err
+		}
+		r.waiting = false
+	}
+
+	message, err := r.conn.Receive()
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+
+	switch message.Type {
+	case RowBatchMessage:
+		r.waiting = true
+		r.batch, err = r.decodeBatch(message.Data)
+		return err
+	case ResultTrailerMessage:
+		var trailer ResultTrailer
+		if err := json.Unmarshal([]byte(message.Data), &trailer); err != nil {
+			return err
+		}
+		r.trailer = &trailer
+		return nil
+	case ErrorMessage:
+		r.trailer = &ResultTrailer{Status: ResultFailed, Error: message.Data}
+		return nil
+	}
+	return UnexpectedMessageError{message.Type}
+}
+
+func (r *ResultReader) decodeBatch(data string) ([][]interface{}, error) {
+	var encoded [][]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &encoded); err != nil {
+		return nil, err
+	}
+
+	rows := make([][]interface{}, len(encoded))
+	for i, values := range encoded {
+		if len(values) != len(r.columns) {
+			return nil, RowLengthError{len(r.columns), len(values)}
+		}
+
+		rows[i] = make([]interface{}, len(values))
+		for j, value := range values {
+			decoded, err := decodeValue(r.columns[j].Type, value)
+			if err != nil {
+				return nil, err
+			}
+			rows[i][j] = decoded
+		}
+	}
+	return rows, nil
+}
+
+//decodeValue decodes a JSON value of a column of type t, so that integers, floats and datetimes keep their Go types.
+func decodeValue(t Type, data json.RawMessage) (interface{}, error) {
+	if string(data) == "null" {
+		return nil, nil
+	}
+
+	var err error
+	var value interface{}
+
+	switch t {
+	case IntegerType:
+		var v int64
+		err = json.Unmarshal(data, &v)
+		value = v
+	case FloatType:
+		var v float64
+		err = json.Unmarshal(data, &v)
+		value = v
+	case BooleanType:
+		var v bool
+		err = json.Unmarshal(data, &v)
+		value = v
+	case CharType:
+		var v string
+		err = json.Unmarshal(data, &v)
+		value = v
+	case DatetimeType:
+		var v time.Time
+		err = json.Unmarshal(data, &v)
+		value = v
+	default:
+		err = json.Unmarshal(data, &value)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,157 @@
+package common
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+//countingRows yields rows with an increasing id and fails with err after limit rows when err isn't nil.
+type countingRows struct {
+	produced int
+	limit    int
+	err      error
+}
+
+func (r *countingRows) Next() (map[string]interface{}, error) {
+	if r.produced >= r.limit {
+		if r.err != nil {
+			return nil, r.err
+		}
+		return nil, io.EOF
+	}
+
+	r.produced++
+	return map[string]interface{}{"id": int64(r.produced), "name": "row"}, nil
+}
+
+var resultColumns = []ResultColumn{{"id", IntegerType}, {"name", CharType}}
+
+//streamRows streams rows from a server goroutine and returns the client connection and the result of StreamResult.
+func streamRows(rows RowIterator, batchSize int) (*MessageConn, <-chan error, func()) {
+	client, server := net.Pipe()
+	done := make(chan error, 1)
+
+	go func() {
+		done <- StreamResult(NewMessageConn(server), resultColumns, rows, batchSize)
+	}()
+
+	return NewMessageConn(client), done, func() {
+		client.Close()
+		server.Close()
+	}
+}
+
+func TestStreamResultBatches(t *testing.T) {
+	rows := &countingRows{limit: 250}
+	conn, done, closeAll := streamRows(rows, 100)
+	defer closeAll()
+
+	reader, err := NewResultReader(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := int64(1); ; i++ {
+		row, err := reader.Next()
+		if err == io.EOF {
+			if i != 251 {
+				t.Errorf("expected 250 rows, got %d", i-1)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if row[0] != i || row[1] != "row" {
+			t.Fatalf("expected row %d, got %v", i, row)
+		}
+	}
+
+	trailer, ok := reader.Trailer()
+	if !ok || trailer.Status != ResultComplete || trailer.Rows != 250 {
+		t.Errorf("expected a complete trailer of 250 rows, got %+v", trailer)
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("StreamResult returned %v", err)
+	}
+}
+
+func TestStreamResultCancel(t *testing.T) {
+	rows := &countingRows{limit: 10000}
+	conn, done, closeAll := streamRows(rows, 100)
+	defer closeAll()
+
+	reader, err := NewResultReader(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 150; i++ {
+		if _, err := reader.Next(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := reader.Cancel(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("StreamResult returned %v", err)
+	}
+
+	trailer, ok := reader.Trailer()
+	if !ok || trailer.Status != ResultCancelled || trailer.Rows != 200 {
+		t.Errorf("expected a cancelled trailer of 200 rows, got %+v", trailer)
+	}
+
+	if rows.produced > 201 {
+		t.Errorf("the writer produced %d rows after the result set was cancelled", rows.produced)
+	}
+
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF after cancelling, got %v", err)
+	}
+}
+
+func TestStreamResultFailure(t *testing.T) {
+	failure := errors.New("disk failure")
+	conn, done, closeAll := streamRows(&countingRows{limit: 120, err: failure}, 100)
+	defer closeAll()
+
+	reader, err := NewResultReader(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read := 0
+	for {
+		_, err := reader.Next()
+		if err == nil {
+			read++
+			continue
+		}
+
+		if serverErr, ok := err.(ServerError); !ok || serverErr.Message != failure.Error() {
+			t.Errorf("expected a ServerError, got %v", err)
+		}
+		break
+	}
+
+	if read != 100 {
+		t.Errorf("expected the 100 rows of the first batch, got %d", read)
+	}
+
+	trailer, ok := reader.Trailer()
+	if !ok || trailer.Status != ResultFailed || trailer.Error != failure.Error() || trailer.Rows != 100 {
+		t.Errorf("expected a failed trailer of 100 rows, got %+v", trailer)
+	}
+
+	if err := <-done; err != failure {
+		t.Errorf("expected StreamResult to return %v, got %v", failure, err)
+	}
+}
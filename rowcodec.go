@@ -0,0 +1,245 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var charColumnType = regexp.MustCompile(`^char\[(\d+)\]$`)
+
+var columnTypeAliases = map[string]Type{
+	"int":      IntegerType,
+	"integer":  IntegerType,
+	"float":    FloatType,
+	"bool":     BooleanType,
+	"boolean":  BooleanType,
+	"datetime": DatetimeType,
+	"char":     CharType,
+}
+
+//ParseColumnType parses a column type of the table metadata, such as "int" or "char[100]". The size
+//is the maximum number of bytes of a char column, or zero when it is not bounded.
+func ParseColumnType(columnType string) (Type, uint16, error) {
+	name := strings.ToLower(strings.TrimSpace(columnType))
+
+	if t, ok := columnTypeAliases[name]; ok {
+		return t, 0, nil
+	}
+
+	if match := charColumnType.FindStringSubmatch(name); match != nil {
+		size, err := strconv.ParseUint(match[1], 10, 16)
+		if err == nil {
+			return CharType, uint16(size), nil
+		}
+	}
+
+	return UnknownType, 0, UnknownTypeError{columnType}
+}
+
+type rowColumn struct {
+	name       string
+	columnType string
+	t          Type
+	size       uint16
+}
+
+//RowCodec encodes and decodes the rows of a table as JSON objects keyed by column name. Values are
+//typed according to the table metadata: numbers, booleans, RFC 3339 datetimes, strings and null.
+type RowCodec struct {
+	columns []rowColumn
+	index   map[string]int
+}
+
+//NewRowCodec creates the RowCodec of a table, parsing the types of its columns.
+func NewRowCodec(table Table) (*RowCodec, error) {
+	if len(table.ColumnNames) != len(table.ColumnTypes) {
+		return nil, ColumnCountError{table.Table_Name, len(table.ColumnNames), len(table.ColumnTypes)}
+	}
+
+	codec := &RowCodec{make([]rowColumn, len(table.ColumnNames)), map[string]int{}}
+	for i, name := range table.ColumnNames {
+		t, size, err := ParseColumnType(table.ColumnTypes[i])
+		if err != nil {
+			return nil, err
+		}
+
+		codec.columns[i] = rowColumn{name, table.ColumnTypes[i], t, size}
+		codec.index[name] = i
+	}
+
+	return codec, nil
+}
+
+//Columns returns the columns of the table, as used by the header of a result set.
+func (c *RowCodec) Columns() []ResultColumn {
+	columns := make([]ResultColumn, len(c.columns))
+	for i, column := range c.columns {
+		columns[i] = ResultColumn{column.name, column.t}
+	}
+	return columns
+}
+
+//column returns the column of the given name or an UnknownIdentifierError.
+func (c *RowCodec) column(name string) (rowColumn, error) {
+	i, ok := c.index[name]
+	if !ok {
+		return rowColumn{}, UnknownIdentifierError{name}
+	}
+	return c.columns[i], nil
+}
+
+//Encode encodes a row as a JSON object with the columns in table order. Missing columns are
+//encoded as null and every value is validated against the type of its column.
+func (c *RowCodec) Encode(row map[string]interface{}) ([]byte, error) {
+	for name := range row {
+		if _, err := c.column(name); err != nil {
+			return nil, err
+		}
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+
+	for i, column := range c.columns {
+		value, err := column.check(row[column.name])
+		if err != nil {
+			return nil, err
+		}
+
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, ColumnValueError{column.name, column.columnType, value}
+		}
+
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		name, _ := json.Marshal(column.name)
+		buffer.Write(name)
+		buffer.WriteByte(':')
+		buffer.Write(encoded)
+	}
+
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+//Decode decodes a row encoded as a JSON object. Missing columns are NULL, and every value must
+//be of the type of its column: int64 for integers, float64 for floats, bool, time.Time or string.
+func (c *RowCodec) Decode(data []byte) (map[string]interface{}, error) {
+	var encoded map[string]json.RawMessage
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return nil, err
+	}
+
+	row := make(map[string]interface{}, len(c.columns))
+	for _, column := range c.columns {
+		row[column.name] = nil
+	}
+
+	for name, raw := range encoded {
+		column, err := c.column(name)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := decodeValue(column.t, raw)
+		if err != nil {
+			return nil, ColumnValueError{column.name, column.columnType, string(raw)}
+		}
+
+		if row[name], err = column.check(value); err != nil {
+			return nil, err
+		}
+	}
+
+	return row, nil
+}
+
+//EncodeRows encodes rows as a JSON array of objects.
+func (c *RowCodec) EncodeRows(rows []map[string]interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte('[')
+
+	for i, row := range rows {
+		encoded, err := c.Encode(row)
+		if err != nil {
+			return nil, err
+		}
+
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		buffer.Write(encoded)
+	}
+
+	buffer.WriteByte(']')
+	return buffer.Bytes(), nil
+}
+
+//DecodeRows decodes a JSON array of rows encoded as objects.
+func (c *RowCodec) DecodeRows(data []byte) ([]map[string]interface{}, error) {
+	var encoded []json.RawMessage
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return nil, err
+	}
+
+	rows := make([]map[string]interface{}, len(encoded))
+	for i, raw := range encoded {
+		row, err := c.Decode(raw)
+		if err != nil {
+			return nil, err
+		}
+		rows[i] = row
+	}
+
+	return rows, nil
+}
+
+//check validates a value against the type of the column and returns it as stored in a row, with
+//integers as int64 and floats as float64. NULL is valid for every column.
+func (c rowColumn) check(value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch c.t {
+	case IntegerType:
+		switch v := value.(type) {
+		case int64:
+			return v, nil
+		case int:
+			return int64(v), nil
+		}
+	case FloatType:
+		switch v := value.(type) {
+		case float64:
+			if !math.IsNaN(v) && !math.IsInf(v, 0) {
+				return v, nil
+			}
+		case int64:
+			return float64(v), nil
+		case int:
+			return float64(v), nil
+		}
+	case BooleanType:
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+	case DatetimeType:
+		if v, ok := value.(time.Time); ok {
+			return v, nil
+		}
+	case CharType:
+		if v, ok := value.(string); ok && (c.size == 0 || len(v) <= int(c.size)) {
+			return v, nil
+		}
+	}
+
+	return nil, ColumnValueError{c.name, c.columnType, value}
+}
@@ -0,0 +1,155 @@
+package common
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseColumnType(t *testing.T) {
+	tests := []struct {
+		columnType string
+		expected   Type
+		size       uint16
+		ok         bool
+	}{
+		{"int", IntegerType, 0, true},
+		{" INTEGER ", IntegerType, 0, true},
+		{"float", FloatType, 0, true},
+		{"Bool", BooleanType, 0, true},
+		{"boolean", BooleanType, 0, true},
+		{"datetime", DatetimeType, 0, true},
+		{"char", CharType, 0, true},
+		{"char[100]", CharType, 100, true},
+		{"CHAR[65535]", CharType, 65535, true},
+		{"char[65536]", UnknownType, 0, false},
+		{"char[]", UnknownType, 0, false},
+		{"varchar", UnknownType, 0, false},
+		{"", UnknownType, 0, false},
+	}
+
+	for _, test := range tests {
+		result, size, err := ParseColumnType(test.columnType)
+		if result != test.expected || size != test.size || (err == nil) != test.ok {
+			t.Errorf("%q: expected %s of size %d, got %s of size %d (%v)", test.columnType, test.expected, test.size, result, size, err)
+		}
+	}
+}
+
+func sampleCodec(t *testing.T) *RowCodec {
+	codec, err := NewRowCodec(Table{"people", []string{"id", "score", "active", "born", "name"}, []string{"int", "float", "bool", "datetime", "char[5]"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return codec
+}
+
+func TestNewRowCodec(t *testing.T) {
+	if _, err := NewRowCodec(Table{"t", []string{"a", "b"}, []string{"int"}}); err != (ColumnCountError{"t", 2, 1}) {
+		t.Errorf("expected a ColumnCountError, got %v", err)
+	}
+	if _, err := NewRowCodec(Table{"t", []string{"a"}, []string{"text"}}); err != (UnknownTypeError{"text"}) {
+		t.Errorf("expected an UnknownTypeError, got %v", err)
+	}
+
+	expected := []ResultColumn{{"id", IntegerType}, {"score", FloatType}, {"active", BooleanType}, {"born", DatetimeType}, {"name", CharType}}
+	if columns := sampleCodec(t).Columns(); !reflect.DeepEqual(columns, expected) {
+		t.Errorf("expected %v, got %v", expected, columns)
+	}
+}
+
+func TestRowCodecRoundTrip(t *testing.T) {
+	codec := sampleCodec(t)
+	born := time.Date(1990, 5, 17, 8, 30, 0, 0, time.UTC)
+
+	rows := []map[string]interface{}{
+		{"id": int64(1), "score": 9.5, "active": true, "born": born, "name": "Ana"},
+		{"id": 2, "score": int64(7), "active": false},
+		{"id": int64(math.MaxInt64), "name": "héé"},
+	}
+
+	encoded, err := codec.EncodeRows(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedJSON := `[{"id":1,"score":9.5,"active":true,"born":"1990-05-17T08:30:00Z","name":"Ana"},` +
+		`{"id":2,"score":7,"active":false,"born":null,"name":null},` +
+		`{"id":9223372036854775807,"score":null,"active":null,"born":null,"name":"héé"}]`
+	if string(encoded) != expectedJSON {
+		t.Errorf("expected %s, got %s", expectedJSON, encoded)
+	}
+
+	decoded, err := codec.DecodeRows(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []map[string]interface{}{
+		{"id": int64(1), "score": 9.5, "active": true, "born": born, "name": "Ana"},
+		{"id": int64(2), "score": 7.0, "active": false, "born": nil, "name": nil},
+		{"id": int64(math.MaxInt64), "score": nil, "active": nil, "born": nil, "name": "héé"},
+	}
+	for i := range expected {
+		for column, value := range expected[i] {
+			if decoded[i][column] != value {
+				t.Errorf("row %d: expected %s = %v (%T), got %v (%T)", i, column, value, value, decoded[i][column], decoded[i][column])
+			}
+		}
+	}
+
+	if row, err := codec.Decode([]byte(`{"id":3}`)); err != nil || len(row) != 5 || row["name"] != nil {
+		t.Errorf("expected missing columns to be NULL, got %v (%v)", row, err)
+	}
+}
+
+func TestRowCodecValidation(t *testing.T) {
+	codec := sampleCodec(t)
+
+	encoded := []map[string]interface{}{
+		{"missing": int64(1)},
+		{"id": "1"},
+		{"id": 1.5},
+		{"score": math.NaN()},
+		{"score": math.Inf(1)},
+		{"active": int64(1)},
+		{"born": "1990-05-17"},
+		{"name": "too long"},
+		{"name": int64(1)},
+	}
+	for _, row := range encoded {
+		if _, err := codec.Encode(row); err == nil {
+			t.Errorf("%v: expected Encode to fail", row)
+		}
+	}
+
+	decoded := []string{
+		`{"missing":1}`,
+		`{"id":"1"}`,
+		`{"id":1.5}`,
+		`{"id":9223372036854775808}`,
+		`{"score":"1"}`,
+		`{"active":1}`,
+		`{"born":"1990-05-17"}`,
+		`{"name":"too long"}`,
+		`{"name":1}`,
+		`[]`,
+		`{`,
+	}
+	for _, data := range decoded {
+		if _, err := codec.Decode([]byte(data)); err == nil {
+			t.Errorf("%s: expected Decode to fail", data)
+		}
+	}
+
+	if _, err := codec.Encode(map[string]interface{}{"name": "too long"}); err != (ColumnValueError{"name", "char[5]", "too long"}) {
+		t.Errorf("expected a ColumnValueError, got %v", err)
+	}
+	if _, err := codec.Decode([]byte(`{"id":"1"}`)); err != (ColumnValueError{"id", "int", `"1"`}) {
+		t.Errorf("expected a ColumnValueError, got %v", err)
+	}
+	if _, err := codec.DecodeRows([]byte(`[{"id":1},{"nope":1}]`)); err != (UnknownIdentifierError{"nope"}) {
+		t.Errorf("expected an UnknownIdentifierError, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//RowIterator yields symbol maps one at a time and returns io.EOF once exhausted.
+type RowIterator interface {
+	Next() (map[string]interface{}, error)
+}
+
+type sliceIterator struct {
+	rows  []map[string]interface{}
+	index int
+}
+
+//NewSliceIterator returns a RowIterator over rows.
+func NewSliceIterator(rows []map[string]interface{}) RowIterator {
+	return &sliceIterator{rows: rows}
+}
+
+func (s *sliceIterator) Next() (map[string]interface{}, error) {
+	if s.index >= len(s.rows) {
+		return nil, io.EOF
+	}
+
+	row := s.rows[s.index]
+	s.index++
+	return row, nil
+}
+
+//CollectRows reads every remaining row of an iterator.
+func CollectRows(rows RowIterator) ([]map[string]interface{}, error) {
+	collected := []map[string]interface{}{}
+
+	for {
+		row, err := rows.Next()
+		if err == io.EOF {
+			return collected, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		collected = append(collected, row)
+	}
+}
+
+//rowKey encodes values into a string that is equal for rows holding equal values.
+func rowKey(values []interface{}) string {
+	var builder strings.Builder
+
+	for _, value := range values {
+		if value != nil {
+			value = hashKey(value)
+		}
+
+		encoded := fmt.Sprintf("%T:%v", value, value)
+		builder.WriteString(strconv.Itoa(len(encoded)))
+		builder.WriteByte(':')
+		builder.WriteString(encoded)
+	}
+
+	return builder.String()
+}
+
+//DistinctRows removes duplicate rows, keeping the first occurrence of each.
+func DistinctRows(rows []map[string]interface{}) []map[string]interface{} {
+	distinct := []map[string]interface{}{}
+	seen := map[string]bool{}
+
+	for _, row := range rows {
+		columns := make([]string, 0, len(row))
+		for column := range row {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+
+		values := make([]interface{}, 0, 2*len(columns))
+		for _, column := range columns {
+			values = append(values, column, row[column])
+		}
+
+		key := rowKey(values)
+		if !seen[key] {
+			seen[key] = true
+			distinct = append(distinct, row)
+		}
+	}
+
+	return distinct
+}
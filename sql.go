@@ -0,0 +1,583 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Operator precedence levels used to decide where parentheses are needed. Higher levels bind tighter.
+const (
+	orPrecedence = iota + 1
+	andPrecedence
+	notPrecedence
+	comparisonPrecedence
+	additivePrecedence
+	multiplicativePrecedence
+	atomPrecedence
+)
+
+var plainIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var reservedWords = map[string]bool{
+	"ADD": true, "ALL": true, "ALTER": true, "AND": true, "AS": true, "ASC": true, "BETWEEN": true, "BY": true,
+	"CASE": true, "CREATE": true, "CROSS": true, "DEFAULT": true, "DELETE": true, "DESC": true, "DISTINCT": true,
+	"DROP": true, "ELSE": true, "END": true, "ESCAPE": true, "EXCEPT": true, "EXISTS": true, "FALSE": true,
+	"FROM": true, "FULL": true, "GROUP": true, "HAVING": true, "ILIKE": true, "IN": true, "INNER": true,
+	"INSERT": true, "INTERSECT": true, "INTO": true, "IS": true, "JOIN": true, "KEY": true, "LEFT": true,
+	"LIKE": true, "LIMIT": true, "MODIFY": true, "NOT": true, "NULL": true, "NULLS": true, "OFFSET": true,
+	"ON": true, "OR": true, "ORDER": true, "OUTER": true, "PRIMARY": true, "RIGHT": true, "SELECT": true,
+	"SET": true, "SYMMETRIC": true, "TABLE": true, "THEN": true, "TRUE": true, "UNION": true, "UPDATE": true,
+	"USING": true, "VALUES": true, "WHEN": true, "WHERE": true,
+}
+
+//QuoteIdentifier returns name as is when it is a plain identifier, or between double quotes otherwise.
+func QuoteIdentifier(name string) string {
+	if plainIdentifier.MatchString(name) && !reservedWords[strings.ToUpper(name)] {
+		return name
+	}
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+//QuoteString returns a SQL string literal.
+func QuoteString(value string) string {
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
+
+func quoteQualified(qualifier string, name string) string {
+	if qualifier == "" {
+		return QuoteIdentifier(name)
+	}
+	return QuoteIdentifier(qualifier) + "." + QuoteIdentifier(name)
+}
+
+//formatFloat renders a float so that it reads back as a float. NaN and infinities have no literal and
+//are rendered as casts of their PostgreSQL spelling.
+func formatFloat(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "CAST('NaN' AS FLOAT)"
+	case math.IsInf(value, 1):
+		return "CAST('Infinity' AS FLOAT)"
+	case math.IsInf(value, -1):
+		return "CAST('-Infinity' AS FLOAT)"
+	}
+
+	formatted := strconv.FormatFloat(value, 'g', -1, 64)
+	if !strings.ContainsAny(formatted, ".e") {
+		formatted += ".0"
+	}
+	return formatted
+}
+
+func formatBool(value bool) string {
+	if value {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+//formatValue renders a value held by a command, such as an inserted value or a column default.
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case Expression:
+		return fmt.Sprint(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return formatFloat(v)
+	case bool:
+		return formatBool(v)
+	case string:
+		return QuoteString(v)
+	case time.Time:
+		return QuoteString(v.Format(time.RFC3339Nano))
+	}
+	return QuoteString(fmt.Sprint(value))
+}
+
+func precedence(expression Expression) int {
+	switch e := expression.(type) {
+	case *OrCommon:
+		return orPrecedence
+	case *AndCommon:
+		return andPrecedence
+	case *NotCommon:
+		return notPrecedence
+	case *ExistsCommon:
+		if e.not {
+			return notPrecedence
+		}
+	case *EqCommon, *NeCommon, *LtCommon, *GtCommon, *LteCommon, *GteCommon, *LikeCommon, *BetweenCommon,
+		*InCommon, *InSelectCommon, *IsNullCommon, *IsBoolCommon, *IsDistinctFromCommon:
+		return comparisonPrecedence
+	case *SumCommon, *SubCommon:
+		return additivePrecedence
+	case *MultCommon, *DivCommon:
+		return multiplicativePrecedence
+	case *IntCommon:
+		if e.value < 0 {
+			return additivePrecedence
+		}
+	case *FloatCommon:
+		if e.value < 0 {
+			return additivePrecedence
+		}
+	}
+	return atomPrecedence
+}
+
+//operand renders an operand, wrapping it in parentheses when it binds looser than minimum.
+func operand(expression Expression, minimum int) string {
+	if precedence(expression) < minimum {
+		return fmt.Sprintf("(%v)", expression)
+	}
+	return fmt.Sprint(expression)
+}
+
+//infix renders a left associative binary operator.
+func infix(left Expression, operator string, right Expression, level int) string {
+	return fmt.Sprintf("%s %s %s", operand(left, level), operator, operand(right, level+1))
+}
+
+//nonAssociative renders a comparison operator, which cannot be chained without parentheses.
+func nonAssociative(left Expression, operator string, right Expression) string {
+	return fmt.Sprintf("%s %s %s", operand(left, comparisonPrecedence+1), operator, operand(right, comparisonPrecedence+1))
+}
+
+func not(negated bool, keyword string) string {
+	if negated {
+		return "NOT " + keyword
+	}
+	return keyword
+}
+
+func joinExpressions(expressions []Expression) string {
+	rendered := make([]string, len(expressions))
+	for i, expression := range expressions {
+		rendered[i] = fmt.Sprint(expression)
+	}
+	return strings.Join(rendered, ", ")
+}
+
+func (id IdCommon) String() string {
+	if id.alias == "" {
+		return QuoteIdentifier(id.name)
+	}
+	return quoteQualified(id.name, id.alias)
+}
+
+func (i IntCommon) String() string {
+	return strconv.FormatInt(i.value, 10)
+}
+
+func (b BoolCommon) String() string {
+	return formatBool(b.value)
+}
+
+func (f FloatCommon) String() string {
+	return formatFloat(f.value)
+}
+
+func (s StringCommon) String() string {
+	return QuoteString(s.value)
+}
+
+func (n NullCommon) String() string {
+	return "NULL"
+}
+
+func (f FalseCommon) String() string {
+	return "FALSE"
+}
+
+func (t TrueCommon) String() string {
+	return "TRUE"
+}
+
+func (a AssignmentCommon) String() string {
+	return fmt.Sprintf("%s = %v", QuoteIdentifier(a.value), a.expression)
+}
+
+func (s SumCommon) String() string {
+	return infix(s.leftValue, "+", s.rightValue, additivePrecedence)
+}
+
+func (s SubCommon) String() string {
+	return infix(s.leftValue, "-", s.rightValue, additivePrecedence)
+}
+
+func (m MultCommon) String() string {
+	return infix(m.leftValue, "*", m.rightValue, multiplicativePrecedence)
+}
+
+func (d DivCommon) String() string {
+	return infix(d.leftValue, "/", d.rightValue, multiplicativePrecedence)
+}
+
+func (e EqCommon) String() string {
+	return nonAssociative(e.leftValue, "=", e.rightValue)
+}
+
+func (ne NeCommon) String() string {
+	return nonAssociative(ne.leftValue, "<>", ne.rightValue)
+}
+
+func (lt LtCommon) String() string {
+	return nonAssociative(lt.leftValue, "<", lt.rightValue)
+}
+
+func (gt GtCommon) String() string {
+	return nonAssociative(gt.leftValue, ">", gt.rightValue)
+}
+
+func (lte LteCommon) String() string {
+	return nonAssociative(lte.leftValue, "<=", lte.rightValue)
+}
+
+func (gte GteCommon) String() string {
+	return nonAssociative(gte.leftValue, ">=", gte.rightValue)
+}
+
+func (b BetweenCommon) String() string {
+	keyword := not(b.not, "BETWEEN")
+	if b.symmetric {
+		keyword += " SYMMETRIC"
+	}
+
+	return fmt.Sprintf("%s %s %s AND %s", operand(b.value, comparisonPrecedence+1), keyword,
+		operand(b.lower, comparisonPrecedence+1), operand(b.upper, comparisonPrecedence+1))
+}
+
+func (l LikeCommon) String() string {
+	like := nonAssociative(l.leftValue, l.operator(), l.rightValue)
+	if l.escape != 0 {
+		like += " ESCAPE " + QuoteString(string(l.escape))
+	}
+	return like
+}
+
+func (in InCommon) String() string {
+	return fmt.Sprintf("%s %s (%s)", operand(in.value, comparisonPrecedence+1), not(in.not, "IN"), joinExpressions(in.list))
+}
+
+func (in InSelectCommon) String() string {
+	return fmt.Sprintf("%s %s (%v)", operand(in.value, comparisonPrecedence+1), not(in.not, "IN"), in.query)
+}
+
+func (n NotCommon) String() string {
+	return "NOT " + operand(n.not, notPrecedence)
+}
+
+func (a AndCommon) String() string {
+	return infix(a.leftValue, "AND", a.rightValue, andPrecedence)
+}
+
+func (o OrCommon) String() string {
+	return infix(o.leftValue, "OR", o.rightValue, orPrecedence)
+}
+
+func (i IsNullCommon) String() string {
+	return fmt.Sprintf("%s IS %s", operand(i.value, comparisonPrecedence+1), not(i.not, "NULL"))
+}
+
+func (i IsBoolCommon) String() string {
+	return fmt.Sprintf("%s IS %s", operand(i.value, comparisonPrecedence+1), not(i.not, formatBool(i.truth)))
+}
+
+func (i IsDistinctFromCommon) String() string {
+	return nonAssociative(i.leftValue, "IS "+not(i.not, "DISTINCT FROM"), i.rightValue)
+}
+
+func (c CaseCommon) String() string {
+	var builder strings.Builder
+
+	builder.WriteString("CASE")
+	if c.operand != nil {
+		fmt.Fprintf(&builder, " %v", c.operand)
+	}
+	for _, when := range c.whens {
+		fmt.Fprintf(&builder, " WHEN %v THEN %v", when.condition, when.result)
+	}
+	if c.elseValue != nil {
+		fmt.Fprintf(&builder, " ELSE %v", c.elseValue)
+	}
+	builder.WriteString(" END")
+
+	return builder.String()
+}
+
+func (f FuncCallCommon) String() string {
+	return fmt.Sprintf("%s(%s)", f.name, joinExpressions(f.args))
+}
+
+func (a AggregateCommon) String() string {
+	argument := "*"
+	if a.argument != nil {
+		argument = fmt.Sprint(a.argument)
+	}
+
+	if a.distinct {
+		return fmt.Sprintf("%s(DISTINCT %s)", a.name, argument)
+	}
+	return fmt.Sprintf("%s(%s)", a.name, argument)
+}
+
+func (s ScalarSubqueryCommon) String() string {
+	return fmt.Sprintf("(%v)", s.query)
+}
+
+func (e ExistsCommon) String() string {
+	return fmt.Sprintf("%s (%v)", not(e.not, "EXISTS"), e.query)
+}
+
+//columnType renders the type of a column definition.
+func columnType(definer TableColumnDefiner) string {
+	switch c := definer.(type) {
+	case CharTableColumn:
+		return fmt.Sprintf("CHAR(%d)", c.size)
+	case *CharTableColumn:
+		return fmt.Sprintf("CHAR(%d)", c.size)
+	}
+	return ColumnType(definer).String()
+}
+
+//columnDefinition renders a column definition as used by CREATE TABLE and ALTER TABLE. A foreign key is
+//left out, since the definer doesn't hold the table it references and FOREIGN KEY alone isn't valid SQL.
+func columnDefinition(definer TableColumnDefiner) string {
+	parts := []string{QuoteIdentifier(definer.ColumnName()), columnType(definer)}
+
+	if !definer.Nullable() {
+		parts = append(parts, "NOT NULL")
+	}
+	if definer.DefaultValue() != nil {
+		parts = append(parts, "DEFAULT", formatValue(definer.DefaultValue()))
+	}
+	if definer.Autoincrementable() {
+		parts = append(parts, "AUTOINCREMENT")
+	}
+	if definer.PrimaryKey() {
+		parts = append(parts, "PRIMARY KEY")
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func (c CreateTableCommand) String() string {
+	columns := make([]string, len(c.tableColumnDefiners))
+	for i, definer := range c.tableColumnDefiners {
+		columns[i] = columnDefinition(definer)
+	}
+	return fmt.Sprintf("CREATE TABLE %s (%s)", QuoteIdentifier(c.tableName), strings.Join(columns, ", "))
+}
+
+func (s TableColumnSelector) String() string {
+	var column string
+
+	switch {
+	case s.isStar && s.prefix != "":
+		column = QuoteIdentifier(s.prefix) + ".*"
+	case s.isStar:
+		column = "*"
+	default:
+		if s.function != nil {
+			column = fmt.Sprint(s.function)
+		} else {
+			column = quoteQualified(s.prefix, s.columnName)
+		}
+	}
+
+	if s.alias != "" {
+		column += " AS " + QuoteIdentifier(s.alias)
+	}
+	return column
+}
+
+func (s TableColumnStarSelector) String() string {
+	return "*"
+}
+
+func (g GroupBySelect) String() string {
+	return quoteQualified(g.table, g.column)
+}
+
+func (o OrderBySelect) String() string {
+	order := fmt.Sprint(o.expression)
+	if o.descending {
+		order += " DESC"
+	}
+
+	switch o.nulls {
+	case NullsFirst:
+		order += " NULLS FIRST"
+	case NullsLast:
+		order += " NULLS LAST"
+	}
+	return order
+}
+
+func tableReference(table string, alias string) string {
+	if alias == "" {
+		return QuoteIdentifier(table)
+	}
+	return fmt.Sprintf("%s AS %s", QuoteIdentifier(table), QuoteIdentifier(alias))
+}
+
+func (j JoinSelect) String() string {
+	join := fmt.Sprintf("%v %s", j.kind, tableReference(j.targetTable, j.targetAlias))
+
+	if len(j.usingColumns) > 0 {
+		columns := make([]string, len(j.usingColumns))
+		for i, column := range j.usingColumns {
+			columns[i] = QuoteIdentifier(column)
+		}
+		join += fmt.Sprintf(" USING (%s)", strings.Join(columns, ", "))
+	}
+
+	if j.filterCriteria != nil {
+		join += fmt.Sprintf(" ON %v", j.filterCriteria)
+	}
+	return join
+}
+
+func (s SelectTableCommand) String() string {
+	var builder strings.Builder
+
+	builder.WriteString("SELECT ")
+	if s.distinct {
+		builder.WriteString("DISTINCT ")
+	}
+
+	if len(s.tableColumnSelectors) == 0 {
+		builder.WriteString("*")
+	}
+	for i, selector := range s.tableColumnSelectors {
+		if i > 0 {
+			builder.WriteString(", ")
+		}
+		fmt.Fprint(&builder, selector)
+	}
+
+	fmt.Fprintf(&builder, " FROM %s", tableReference(s.tableName, s.mainAlias))
+
+	for _, join := range s.joinList {
+		fmt.Fprintf(&builder, " %v", join)
+	}
+
+	if s.whereExpression != nil {
+		fmt.Fprintf(&builder, " WHERE %v", s.whereExpression)
+	}
+
+	if len(s.groupBy) > 0 {
+		columns := make([]string, len(s.groupBy))
+		for i, column := range s.groupBy {
+			columns[i] = column.String()
+		}
+		fmt.Fprintf(&builder, " GROUP BY %s", strings.Join(columns, ", "))
+	}
+
+	if s.havingExpression != nil {
+		fmt.Fprintf(&builder, " HAVING %v", s.havingExpression)
+	}
+
+	if len(s.orderBy) > 0 {
+		keys := make([]string, len(s.orderBy))
+		for i, order := range s.orderBy {
+			keys[i] = order.String()
+		}
+		fmt.Fprintf(&builder, " ORDER BY %s", strings.Join(keys, ", "))
+	}
+
+	if limit, ok := s.Limit(); ok {
+		fmt.Fprintf(&builder, " LIMIT %d", limit)
+	}
+	if s.offset > 0 {
+		fmt.Fprintf(&builder, " OFFSET %d", s.offset)
+	}
+
+	return builder.String()
+}
+
+func (c CompoundSelectCommand) String() string {
+	operator := c.operator.String()
+	if c.all {
+		operator += " ALL"
+	}
+	return fmt.Sprintf("%s %s %s", compoundOperand(c.left), operator, compoundOperand(c.right))
+}
+
+//compoundOperand renders a side of a compound select, in parentheses when its ORDER BY, LIMIT or
+//OFFSET would otherwise apply to the whole compound select.
+func compoundOperand(query *SelectTableCommand) string {
+	_, limited := query.Limit()
+	if len(query.orderBy) > 0 || limited || query.offset > 0 {
+		return "(" + query.String() + ")"
+	}
+	return query.String()
+}
+
+func (i InsertCommand) String() string {
+	columns := make([]string, 0, len(i.values))
+	for column := range i.values {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	quoted := make([]string, len(columns))
+	values := make([]string, len(columns))
+	for j, column := range columns {
+		quoted[j] = QuoteIdentifier(column)
+		values[j] = formatValue(i.values[column])
+	}
+
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", QuoteIdentifier(i.tableName), strings.Join(quoted, ", "), strings.Join(values, ", "))
+}
+
+func (c UpdateTableCommand) String() string {
+	assignments := make([]string, len(c.assignments))
+	for i, assignment := range c.assignments {
+		assignments[i] = assignment.String()
+	}
+
+	update := fmt.Sprintf("UPDATE %s SET %s", QuoteIdentifier(c.tableName), strings.Join(assignments, ", "))
+	if c.where != nil {
+		update += fmt.Sprintf(" WHERE %v", c.where)
+	}
+	return update
+}
+
+func (c DeleteCommand) String() string {
+	del := fmt.Sprintf("DELETE FROM %s", tableReference(c.tableName, c.alias))
+	if c.where != nil {
+		del += fmt.Sprintf(" WHERE %v", c.where)
+	}
+	return del
+}
+
+func (i DropCommand) String() string {
+	return fmt.Sprintf("DROP TABLE %s", QuoteIdentifier(i.tableName))
+}
+
+func (a AlterDropInst) String() string {
+	return fmt.Sprintf("DROP COLUMN %s", QuoteIdentifier(a.table))
+}
+
+func (a AlterAddInst) String() string {
+	return fmt.Sprintf("ADD COLUMN %s", columnDefinition(a.tableColumnDefiners))
+}
+
+func (a AlterModifyInst) String() string {
+	return fmt.Sprintf("MODIFY COLUMN %s", columnDefinition(a.tableColumnDefiners))
+}
+
+func (a AlterCommand) String() string {
+	return fmt.Sprintf("ALTER TABLE %s %v", QuoteIdentifier(a.table), a.instruction)
+}
@@ -0,0 +1,131 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{1, "1.0"},
+		{-2.5, "-2.5"},
+		{1e21, "1e+21"},
+		{math.NaN(), "CAST('NaN' AS FLOAT)"},
+		{math.Inf(1), "CAST('Infinity' AS FLOAT)"},
+		{math.Inf(-1), "CAST('-Infinity' AS FLOAT)"},
+	}
+
+	for _, test := range tests {
+		if formatted := NewFloatCommon(test.value).String(); formatted != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, formatted)
+		}
+	}
+}
+
+func TestExpressionString(t *testing.T) {
+	a, b := NewIdCommon("a", ""), NewIdCommon("t", "b")
+
+	tests := []struct {
+		expression Expression
+		expected   string
+	}{
+		{NewMultCommon(NewIntCommon(2), NewSumCommon(b, a)), "(a + t.b) * 2"},
+		{NewSubCommon(NewSubCommon(NewIntCommon(1), b), a), "a - (t.b - 1)"},
+		{NewNotCommon(NewAndCommon(NewTrueCommon(), NewEqCommon(b, a))), "NOT (a = t.b AND TRUE)"},
+		{NewIsNullCommon(a, true), "a IS NOT NULL"},
+		{NewInCommon(a, []Expression{NewIntCommon(1), NewStringCommon("it's")}, true), "a NOT IN (1, 'it''s')"},
+		{NewEqCommon(NewNullCommon(), NewIdCommon("select", "")), `"select" = NULL`},
+	}
+
+	for _, test := range tests {
+		if rendered := fmt.Sprint(test.expression); rendered != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, rendered)
+		}
+	}
+}
+
+func sqlSelect(table string, where Expression, orderBy []OrderBySelect, limit int64, hasLimit bool, offset int64) *SelectTableCommand {
+	selectors := TableColumnSelectors{NewTableColumnSelector(false, "", "a", "", nil)}
+	return NewSelectTableCommand(table, "", selectors, nil, where, nil, nil, orderBy, limit, hasLimit, offset, false)
+}
+
+func TestCompoundSelectString(t *testing.T) {
+	orderBy := []OrderBySelect{*NewOrderBySelect(NewIdCommon("a", ""), true, NullsDefault)}
+	plain := sqlSelect("t", nil, nil, 0, false, 0)
+
+	tests := []struct {
+		query    *CompoundSelectCommand
+		expected string
+	}{
+		{NewCompoundSelectCommand(plain, sqlSelect("u", nil, nil, 0, false, 0), Union, false),
+			"SELECT a FROM t UNION SELECT a FROM u"},
+		{NewCompoundSelectCommand(plain, sqlSelect("u", nil, orderBy, 0, false, 0), Union, true),
+			"SELECT a FROM t UNION ALL (SELECT a FROM u ORDER BY a DESC)"},
+		{NewCompoundSelectCommand(sqlSelect("u", nil, nil, 5, true, 0), plain, Intersect, false),
+			"(SELECT a FROM u LIMIT 5) INTERSECT SELECT a FROM t"},
+		{NewCompoundSelectCommand(plain, sqlSelect("u", nil, nil, 0, false, 2), Except, false),
+			"SELECT a FROM t EXCEPT (SELECT a FROM u OFFSET 2)"},
+	}
+
+	for _, test := range tests {
+		if rendered := test.query.String(); rendered != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, rendered)
+		}
+	}
+}
+
+func TestClausesWithoutValidSQLAreLeftOut(t *testing.T) {
+	on := NewEqCommon(NewIdCommon("u", "a"), NewIdCommon("t", "a"))
+	selectors := TableColumnSelectors{NewTableColumnSelector(false, "", "a", "", nil)}
+	joins := []JoinSelect{*NewJoinSelectWithKind(CrossJoin, "u", "", on, []string{"a"})}
+
+	tests := []struct {
+		command  Command
+		expected string
+	}{
+		{NewCommand(NewSelectTableCommand("t", "", selectors, joins, nil, nil, nil, nil, 0, false, 0, false), Select, nil),
+			"SELECT a FROM t CROSS JOIN u"},
+		{NewCommand(NewCreateTableCommand("t", TableColumnDefiners{NewIntegerTableColumn("a", nil, false, false, true, true)}), Create, nil),
+			"CREATE TABLE t (a INTEGER NOT NULL PRIMARY KEY)"},
+	}
+
+	for _, test := range tests {
+		if rendered := test.command.String(); rendered != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, rendered)
+		}
+	}
+}
+
+func TestCommandStringRoundTrip(t *testing.T) {
+	where := NewAndCommon(NewGtCommon(NewFloatCommon(math.Inf(1)), NewIdCommon("b", "")), NewLikeCommon(NewStringCommon("x%"), NewIdCommon("a", "")))
+	orderBy := []OrderBySelect{*NewOrderBySelect(NewIdCommon("a", ""), false, NullsFirst)}
+
+	commands := []Command{
+		NewCommand(sqlSelect("t", where, orderBy, 10, true, 5), Select, nil),
+		NewCommand(NewCompoundSelectCommand(sqlSelect("t", nil, orderBy, 1, true, 0), sqlSelect("u", where, nil, 0, false, 0), Union, false), CompoundSelect, nil),
+		NewCommand(NewInsertCommand("t", map[string]interface{}{"a": "it's", "b": math.NaN(), "c": nil}), Insert, nil),
+		NewCommand(NewDeleteTableCommand("t", "", NewIsNullCommon(NewIdCommon("a", ""), false)), Delete, nil),
+	}
+
+	for _, command := range commands {
+		expected := command.String()
+
+		encoded, err := command.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%s: %v", expected, err)
+		}
+
+		var decoded Command
+		if err := decoded.UnmarshalBinary(encoded); err != nil {
+			t.Fatalf("%s: %v", expected, err)
+		}
+
+		if rendered := decoded.String(); rendered != expected {
+			t.Errorf("expected %s, got %s", expected, rendered)
+		}
+	}
+}
@@ -1,16 +1,14 @@
 package common
 
-type comms struct {
-	Type int    `json:"Type"`
-	Data string `json:"Data"`
+//Database is the metadata of a database and of its tables.
+type Database struct {
+	DB_Name string  `json:"DB_Name"`
+	Tables  []Table `json:"Tables"`
 }
 
-type database struct {
-	DB_Name string `json:"DB_Name"`
-	Tables  table  `json:"Tables"`
-}
-
-type table struct {
+//Table is the metadata of a table. ColumnTypes holds the type of each column of ColumnNames,
+//such as "int", "boolean" or "char[100]".
+type Table struct {
 	Table_Name  string   `json:"Table_Name"`
 	ColumnNames []string `json:"ColumnNames"`
 	ColumnTypes []string `json:"ColumnTypes"`
@@ -0,0 +1,175 @@
+package common
+
+import (
+	"errors"
+	"sync"
+)
+
+//SubqueryExecutor runs a nested select on behalf of an expression. The outer argument holds
+//the symbols of the row being evaluated, and the result contains one slice per projected row.
+type SubqueryExecutor func(query *SelectTableCommand, outer map[string]interface{}) ([][]interface{}, error)
+
+//ErrNoSubqueryExecutor is returned when a subquery is evaluated without an executor.
+var ErrNoSubqueryExecutor = errors.New("No subquery executor has been set")
+
+var (
+	subqueryExecutorMutex sync.RWMutex
+	subqueryExecutor      SubqueryExecutor
+)
+
+//SetSubqueryExecutor sets the default executor, used by the subquery expressions that are not bound to
+//an executor with Bind.
+func SetSubqueryExecutor(executor SubqueryExecutor) {
+	subqueryExecutorMutex.Lock()
+	defer subqueryExecutorMutex.Unlock()
+
+	subqueryExecutor = executor
+}
+
+//executeSubquery runs a subquery with the executor bound to its expression, or with the default executor when it is nil.
+func executeSubquery(executor SubqueryExecutor, query *SelectTableCommand, outer map[string]interface{}) ([][]interface{}, error) {
+	if executor == nil {
+		subqueryExecutorMutex.RLock()
+		executor = subqueryExecutor
+		subqueryExecutorMutex.RUnlock()
+	}
+
+	if executor == nil {
+		return nil, ErrNoSubqueryExecutor
+	}
+
+	return executor(query, outer)
+}
+
+//InSelectCommon tests whether a value is returned by a single column subquery.
+type InSelectCommon struct {
+	value    Expression
+	query    *SelectTableCommand
+	not      bool
+	executor SubqueryExecutor
+}
+
+func (in InSelectCommon) Evaluate(symbols map[string]interface{}) interface{} {
+	return mustEvaluate(in, symbols)
+}
+
+func (in InSelectCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	value, err := in.value.EvaluateE(symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := executeSubquery(in.executor, in.query, symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]interface{}, len(rows))
+	for i, row := range rows {
+		if len(row) != 1 {
+			return nil, SubqueryColumnsError{len(row)}
+		}
+		candidates[i] = row[0]
+	}
+
+	return inList(value, candidates, in.not), nil
+}
+
+func (in InSelectCommon) Children() []Expression {
+	return []Expression{in.value}
+}
+
+//NewInSelectCommon creates an instance of InSelectCommon for `value [NOT] IN (SELECT ...)'.
+func NewInSelectCommon(value Expression, query *SelectTableCommand, not bool) *InSelectCommon {
+	return &InSelectCommon{value: value, query: query, not: not}
+}
+
+//ScalarSubqueryCommon evaluates to the single value returned by a subquery, or NULL when it returns no rows.
+type ScalarSubqueryCommon struct {
+	query    *SelectTableCommand
+	executor SubqueryExecutor
+}
+
+func (s ScalarSubqueryCommon) Evaluate(symbols map[string]interface{}) interface{} {
+	return mustEvaluate(s, symbols)
+}
+
+func (s ScalarSubqueryCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	rows, err := executeSubquery(s.executor, s.query, symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(rows) == 0:
+		return nil, nil
+	case len(rows) > 1:
+		return nil, ScalarSubqueryRowsError{len(rows)}
+	case len(rows[0]) != 1:
+		return nil, SubqueryColumnsError{len(rows[0])}
+	}
+
+	return rows[0][0], nil
+}
+
+func (s ScalarSubqueryCommon) Children() []Expression {
+	return nil
+}
+
+func NewScalarSubqueryCommon(query *SelectTableCommand) *ScalarSubqueryCommon {
+	return &ScalarSubqueryCommon{query: query}
+}
+
+//ExistsCommon tests whether a subquery returns any row.
+type ExistsCommon struct {
+	query    *SelectTableCommand
+	not      bool
+	executor SubqueryExecutor
+}
+
+func (e ExistsCommon) Evaluate(symbols map[string]interface{}) interface{} {
+	return mustEvaluate(e, symbols)
+}
+
+func (e ExistsCommon) EvaluateE(symbols map[string]interface{}) (interface{}, error) {
+	rows, err := executeSubquery(e.executor, e.query, symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	return (len(rows) > 0) != e.not, nil
+}
+
+func (e ExistsCommon) Children() []Expression {
+	return nil
+}
+
+//NewExistsCommon creates an instance of ExistsCommon for `[NOT] EXISTS (SELECT ...)'.
+func NewExistsCommon(query *SelectTableCommand, not bool) *ExistsCommon {
+	return &ExistsCommon{query: query, not: not}
+}
+
+//CorrelatedSymbols returns the symbols of an inner row extended with the symbols of the outer row,
+//so that the identifiers of a correlated subquery not defined by the inner row read the outer row.
+func CorrelatedSymbols(outer map[string]interface{}, inner map[string]interface{}) map[string]interface{} {
+	return mergeRows(outer, inner)
+}
+
+type correlatedIterator struct {
+	outer map[string]interface{}
+	rows  RowIterator
+}
+
+//NewCorrelatedIterator wraps the rows of a subquery so that each of them also resolves the outer row symbols.
+//Executors use it with the outer symbols they receive to evaluate correlated conditions.
+func NewCorrelatedIterator(outer map[string]interface{}, rows RowIterator) RowIterator {
+	return &correlatedIterator{outer, rows}
+}
+
+func (c *correlatedIterator) Next() (map[string]interface{}, error) {
+	row, err := c.rows.Next()
+	if err != nil {
+		return nil, err
+	}
+	return CorrelatedSymbols(c.outer, row), nil
+}
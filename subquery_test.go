@@ -0,0 +1,159 @@
+package common
+
+import (
+	"io"
+	"testing"
+)
+
+//subqueryRows returns an executor answering every subquery with rows.
+func subqueryRows(rows ...[]interface{}) SubqueryExecutor {
+	return func(*SelectTableCommand, map[string]interface{}) ([][]interface{}, error) {
+		return rows, nil
+	}
+}
+
+func TestInSelect(t *testing.T) {
+	query := NewSelectTableCommand("t", "", nil, nil, nil, nil, nil, nil, 0, false, 0, false)
+
+	tests := []struct {
+		name     string
+		value    interface{}
+		rows     [][]interface{}
+		expected interface{}
+		notIn    interface{}
+	}{
+		{"match", int64(1), [][]interface{}{{int64(1)}, {int64(2)}}, true, false},
+		{"no match", int64(3), [][]interface{}{{int64(1)}, {int64(2)}}, false, true},
+		{"no match with NULL", int64(3), [][]interface{}{{int64(1)}, {nil}}, nil, nil},
+		{"NULL value", nil, [][]interface{}{{int64(1)}}, nil, nil},
+		{"no rows", nil, nil, false, true},
+	}
+
+	for _, test := range tests {
+		environment := Environment{Subqueries: subqueryRows(test.rows...)}
+		symbols := map[string]interface{}{"a": test.value}
+
+		for not, expected := range map[bool]interface{}{false: test.expected, true: test.notIn} {
+			in := Bind(NewInSelectCommon(NewIdCommon("a", ""), query, not), environment)
+
+			if value, err := in.EvaluateE(symbols); err != nil || value != expected {
+				t.Errorf("%s (NOT %v): expected %v, got %v (%v)", test.name, not, expected, value, err)
+			}
+		}
+	}
+
+	in := Bind(NewInSelectCommon(NewIntCommon(1), query, false), Environment{Subqueries: subqueryRows([]interface{}{int64(1), int64(2)})})
+	if _, err := in.EvaluateE(nil); err != (SubqueryColumnsError{2}) {
+		t.Errorf("expected a SubqueryColumnsError, got %v", err)
+	}
+}
+
+func TestScalarAndExistsSubqueries(t *testing.T) {
+	query := NewSelectTableCommand("t", "", nil, nil, nil, nil, nil, nil, 0, false, 0, false)
+
+	tests := []struct {
+		name             string
+		rows             [][]interface{}
+		scalar           interface{}
+		err              error
+		exists, notExist bool
+	}{
+		{"no rows", nil, nil, nil, false, true},
+		{"one row", [][]interface{}{{int64(7)}}, int64(7), nil, true, false},
+		{"NULL row", [][]interface{}{{nil}}, nil, nil, true, false},
+		{"two rows", [][]interface{}{{int64(1)}, {int64(2)}}, nil, ScalarSubqueryRowsError{2}, true, false},
+		{"two columns", [][]interface{}{{int64(1), int64(2)}}, nil, SubqueryColumnsError{2}, true, false},
+	}
+
+	for _, test := range tests {
+		environment := Environment{Subqueries: subqueryRows(test.rows...)}
+
+		value, err := Bind(NewScalarSubqueryCommon(query), environment).EvaluateE(nil)
+		if err != test.err || value != test.scalar {
+			t.Errorf("%s: expected scalar %v (%v), got %v (%v)", test.name, test.scalar, test.err, value, err)
+		}
+
+		for not, expected := range map[bool]bool{false: test.exists, true: test.notExist} {
+			if value, err := Bind(NewExistsCommon(query, not), environment).EvaluateE(nil); err != nil || value != expected {
+				t.Errorf("%s (NOT %v): expected EXISTS %v, got %v (%v)", test.name, not, expected, value, err)
+			}
+		}
+	}
+}
+
+func TestCorrelatedSubquery(t *testing.T) {
+	inner := []map[string]interface{}{
+		{"i.k": int64(1), "i.v": "one"},
+		{"i.k": int64(2), "i.v": "two"},
+		{"i.k": int64(2), "i.v": "deux"},
+	}
+
+	//executor filters the inner rows with the WHERE condition of the query, resolving the outer identifiers.
+	executor := func(query *SelectTableCommand, outer map[string]interface{}) ([][]interface{}, error) {
+		rows := NewCorrelatedIterator(outer, NewSliceIterator(inner))
+
+		result := [][]interface{}{}
+		for {
+			row, err := rows.Next()
+			if err == io.EOF {
+				return result, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			ok, err := EvaluatePredicate(query.Condition(), row)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				result = append(result, []interface{}{row["i.v"]})
+			}
+		}
+	}
+
+	where := NewEqCommon(NewIdCommon("a", ""), NewIdCommon("i", "k"))
+	query := NewSelectTableCommand("i", "", nil, nil, where, nil, nil, nil, 0, false, 0, false)
+	scalar := Bind(NewScalarSubqueryCommon(query), Environment{Subqueries: executor})
+
+	tests := []struct {
+		a        interface{}
+		expected interface{}
+		err      error
+	}{
+		{int64(1), "one", nil},
+		{int64(3), nil, nil},
+		{nil, nil, nil},
+		{int64(2), nil, ScalarSubqueryRowsError{2}},
+	}
+
+	for _, test := range tests {
+		value, err := scalar.EvaluateE(map[string]interface{}{"a": test.a})
+		if err != test.err || value != test.expected {
+			t.Errorf("a = %v: expected %v (%v), got %v (%v)", test.a, test.expected, test.err, value, err)
+		}
+	}
+
+	if symbols := CorrelatedSymbols(map[string]interface{}{"a": 1, "b": 2}, map[string]interface{}{"a": 3}); symbols["a"] != 3 || symbols["b"] != 2 {
+		t.Errorf("expected the inner symbols to shadow the outer ones, got %v", symbols)
+	}
+}
+
+func TestDefaultSubqueryExecutor(t *testing.T) {
+	query := NewSelectTableCommand("t", "", nil, nil, nil, nil, nil, nil, 0, false, 0, false)
+	exists := NewExistsCommon(query, false)
+
+	if _, err := exists.EvaluateE(nil); err != ErrNoSubqueryExecutor {
+		t.Errorf("expected ErrNoSubqueryExecutor, got %v", err)
+	}
+
+	SetSubqueryExecutor(subqueryRows([]interface{}{int64(1)}))
+	defer SetSubqueryExecutor(nil)
+
+	if value, err := exists.EvaluateE(nil); err != nil || value != true {
+		t.Errorf("expected the default executor to be used, got %v (%v)", value, err)
+	}
+	if value, err := Bind(exists, Environment{Subqueries: subqueryRows()}).EvaluateE(nil); err != nil || value != false {
+		t.Errorf("expected the bound executor to be used, got %v (%v)", value, err)
+	}
+}
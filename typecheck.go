@@ -0,0 +1,464 @@
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+//Type is the type of a column or of the result of an expression.
+type Type int
+
+//Type constants. UnknownType is used when a type cannot be inferred.
+const (
+	UnknownType Type = iota
+	NullType
+	IntegerType
+	FloatType
+	BooleanType
+	DatetimeType
+	CharType
+)
+
+var typeName = map[Type]string{
+	UnknownType:  "UNKNOWN",
+	NullType:     "NULL",
+	IntegerType:  "INTEGER",
+	FloatType:    "FLOAT",
+	BooleanType:  "BOOLEAN",
+	DatetimeType: "DATETIME",
+	CharType:     "CHAR",
+}
+
+func (t Type) String() string {
+	return typeName[t]
+}
+
+//MarshalText encodes the type by its name.
+func (t Type) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+//UnmarshalText decodes a type encoded by MarshalText.
+func (t *Type) UnmarshalText(text []byte) error {
+	for value, name := range typeName {
+		if name == string(text) {
+			*t = value
+			return nil
+		}
+	}
+	return UnknownTypeError{string(text)}
+}
+
+func (t Type) numeric() bool {
+	return t == IntegerType || t == FloatType
+}
+
+//known returns false for the NULL and UNKNOWN types, which are accepted by every operator.
+func (t Type) known() bool {
+	return t != UnknownType && t != NullType
+}
+
+func returns(t Type) func([]Type) Type {
+	return func([]Type) Type {
+		return t
+	}
+}
+
+func firstArgType(args []Type) Type {
+	return args[0]
+}
+
+//ColumnType returns the type of a column definition.
+func ColumnType(definer TableColumnDefiner) Type {
+	switch definer.(type) {
+	case IntegerTableColumn, *IntegerTableColumn:
+		return IntegerType
+	case FloatTableColumn, *FloatTableColumn:
+		return FloatType
+	case BooleanTableColumn, *BooleanTableColumn:
+		return BooleanType
+	case DatetimeTableColumn, *DatetimeTableColumn:
+		return DatetimeType
+	case CharTableColumn, *CharTableColumn:
+		return CharType
+	}
+	return UnknownType
+}
+
+//Schema maps the identifiers read by IdCommon to their types.
+type Schema map[string]Type
+
+//NewSchema creates the schema of a table. Every column is available by its name and, when the
+//qualifier isn't empty, prefixed by the qualifier as in `alias.column'.
+func NewSchema(qualifier string, table *CreateTableCommand) Schema {
+	schema := Schema{}
+
+	for _, definer := range table.TableColumnDefiners() {
+		schema[definer.ColumnName()] = ColumnType(definer)
+		if qualifier != "" {
+			schema[qualify(qualifier, definer.ColumnName())] = ColumnType(definer)
+		}
+	}
+
+	return schema
+}
+
+//Merge returns a schema holding the identifiers of both schemas. Unqualified columns defined by both
+//are ambiguous and left out, so they must be referenced with their qualifier.
+func (s Schema) Merge(other Schema) Schema {
+	merged := Schema{}
+
+	for key, t := range s {
+		merged[key] = t
+	}
+
+	for key, t := range other {
+		if _, ok := merged[key]; ok && !strings.Contains(key, ".") {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = t
+	}
+
+	return merged
+}
+
+//TypeError describes a type error found in an expression. The position lists the index of each
+//child followed from the root of the clause, as in `WHERE/0/1'.
+type TypeError struct {
+	Position string
+	Node     string
+	Message  string
+}
+
+func (e TypeError) Error() string {
+	return fmt.Sprintf("Type error at %s (%s): %s", e.Position, e.Node, e.Message)
+}
+
+//TypeErrors holds every type error found in a type check.
+type TypeErrors []TypeError
+
+func (e TypeErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+//typeChecker infers types of expressions. When aggregateSchema is set, as in a HAVING clause, it is
+//used for the arguments of aggregates instead of schema. When types is set, the type of every node
+//created by a constructor is recorded in it.
+type typeChecker struct {
+	schema          Schema
+	aggregateSchema Schema
+	types           map[Expression]Type
+	errors          TypeErrors
+}
+
+//TypeCheck infers the type of an expression using the column types of the schema, reporting every error found.
+//The expression is only read, so it may be checked against several schemas concurrently.
+func TypeCheck(expression Expression, schema Schema) (Type, error) {
+	checker := &typeChecker{schema: schema}
+
+	t := checker.check(expression, "$")
+	if len(checker.errors) > 0 {
+		return t, checker.errors
+	}
+	return t, nil
+}
+
+//TypeCheckSelect type checks the clauses of a select query and verifies that its WHERE, ON and HAVING clauses are boolean.
+func TypeCheckSelect(query *SelectTableCommand, schema Schema) error {
+	checker := &typeChecker{schema: schema}
+
+	checker.checkCondition(query.whereExpression, "WHERE")
+	for i, join := range query.joinList {
+		checker.checkCondition(join.filterCriteria, fmt.Sprintf("JOIN[%d]", i))
+	}
+
+	if query.havingExpression != nil {
+		grouped := Schema{}
+		for _, column := range query.groupBy {
+			grouped[column.key()] = schema[column.key()]
+		}
+
+		having := &typeChecker{schema: grouped, aggregateSchema: schema}
+		having.checkCondition(query.havingExpression, "HAVING")
+		checker.errors = append(checker.errors, having.errors...)
+	}
+
+	for i, order := range query.orderBy {
+		checker.check(order.expression, fmt.Sprintf("ORDER BY[%d]", i))
+	}
+
+	if len(checker.errors) > 0 {
+		return checker.errors
+	}
+	return nil
+}
+
+func (c *typeChecker) checkCondition(expression Expression, clause string) {
+	if expression == nil {
+		return
+	}
+
+	if t := c.check(expression, clause); t.known() && t != BooleanType {
+		c.fail(expression, clause, "%s clause must be BOOLEAN, not %s", clause, t)
+	}
+}
+
+func (c *typeChecker) fail(expression Expression, position string, format string, args ...interface{}) {
+	node := reflect.Indirect(reflect.ValueOf(expression)).Type().Name()
+	c.errors = append(c.errors, TypeError{position, node, fmt.Sprintf(format, args...)})
+}
+
+//operands checks the children of an expression and returns their types.
+func (c *typeChecker) operands(expression Expression, position string) []Type {
+	children := expression.Children()
+
+	types := make([]Type, len(children))
+	for i, child := range children {
+		types[i] = c.check(child, c.child(position, i))
+	}
+	return types
+}
+
+//child returns the position of the child of index i.
+func (c *typeChecker) child(position string, i int) string {
+	return fmt.Sprintf("%s/%d", position, i)
+}
+
+//inferTypes returns the type of every node of an expression under the column types of the schema.
+func inferTypes(expression Expression, schema Schema) map[Expression]Type {
+	checker := &typeChecker{schema: schema, types: map[Expression]Type{}}
+	checker.check(expression, "$")
+	return checker.types
+}
+
+func (c *typeChecker) check(expression Expression, position string) Type {
+	t := c.infer(expression, position)
+	if c.types != nil && reflect.ValueOf(expression).Kind() == reflect.Ptr {
+		c.types[expression] = t
+	}
+	return t
+}
+
+func (c *typeChecker) infer(expression Expression, position string) Type {
+	switch e := expression.(type) {
+	case *IdCommon:
+		if t, ok := c.schema[e.Key()]; ok {
+			return t
+		}
+		c.fail(e, position, "identifier `%s' does not exist", e.Key())
+		return UnknownType
+	case *AggregateCommon:
+		return c.checkAggregate(e, position)
+	case *ScalarSubqueryCommon:
+		return UnknownType
+	case *InSelectCommon, *ExistsCommon:
+		c.operands(expression, position)
+		return BooleanType
+	case *FuncCallCommon:
+		return c.checkFuncCall(e, position)
+	case *CaseCommon:
+		return c.checkCase(e, position)
+	}
+
+	if value, ok := constantValue(expression); ok {
+		return valueType(value)
+	}
+
+	operands := c.operands(expression, position)
+
+	switch e := expression.(type) {
+	case *SumCommon, *SubCommon, *MultCommon, *DivCommon:
+		return c.arithmetic(e, position, operands[0], operands[1])
+	case *EqCommon, *NeCommon, *IsDistinctFromCommon, *InCommon:
+		for _, operand := range operands[1:] {
+			c.comparable(e, position, operands[0], operand, false)
+		}
+		return BooleanType
+	case *LtCommon, *GtCommon, *LteCommon, *GteCommon, *BetweenCommon:
+		for _, operand := range operands[1:] {
+			c.comparable(e, position, operands[0], operand, true)
+		}
+		return BooleanType
+	case *LikeCommon:
+		c.expect(e, position, CharType, operands...)
+		return BooleanType
+	case *NotCommon, *AndCommon, *OrCommon, *IsBoolCommon:
+		c.expect(e, position, BooleanType, operands...)
+		return BooleanType
+	case *IsNullCommon:
+		return BooleanType
+	}
+
+	c.fail(expression, position, "unsupported expression %T", expression)
+	return UnknownType
+}
+
+func valueType(value interface{}) Type {
+	switch value.(type) {
+	case nil:
+		return NullType
+	case int64:
+		return IntegerType
+	case float64:
+		return FloatType
+	case bool:
+		return BooleanType
+	case string:
+		return CharType
+	case time.Time:
+		return DatetimeType
+	}
+	return UnknownType
+}
+
+func (c *typeChecker) arithmetic(expression Expression, position string, left Type, right Type) Type {
+	for _, t := range []Type{left, right} {
+		if t.known() && !t.numeric() {
+			c.fail(expression, position, "undefined arithmetic operator for types %s and %s", left, right)
+			return UnknownType
+		}
+	}
+
+	switch {
+	case left == FloatType || right == FloatType:
+		return FloatType
+	case left == IntegerType && right == IntegerType:
+		return IntegerType
+	case left == NullType || right == NullType:
+		return NullType
+	}
+	return UnknownType
+}
+
+func (c *typeChecker) comparable(expression Expression, position string, left Type, right Type, ordered bool) {
+	if !left.known() || !right.known() || (left.numeric() && right.numeric()) {
+		return
+	}
+
+	if left != right || (ordered && left == BooleanType) {
+		c.fail(expression, position, "cannot compare %s with %s", left, right)
+	}
+}
+
+func (c *typeChecker) expect(expression Expression, position string, expected Type, operands ...Type) {
+	for _, t := range operands {
+		if t.known() && t != expected {
+			c.fail(expression, position, "expected %s operand, got %s", expected, t)
+			return
+		}
+	}
+}
+
+func (c *typeChecker) checkAggregate(aggregate *AggregateCommon, position string) Type {
+	if aggregate.argument == nil {
+		return IntegerType
+	}
+
+	var argument Type
+	if c.aggregateSchema == nil {
+		argument = c.check(aggregate.argument, c.child(position, 0))
+	} else {
+		checker := &typeChecker{schema: c.aggregateSchema}
+		argument = checker.check(aggregate.argument, c.child(position, 0))
+		c.errors = append(c.errors, checker.errors...)
+	}
+
+	switch aggregate.name {
+	case "COUNT":
+		return IntegerType
+	case "AVG":
+		c.expectNumeric(aggregate, position, argument)
+		return FloatType
+	case "SUM":
+		c.expectNumeric(aggregate, position, argument)
+		return argument
+	case "MIN", "MAX":
+		return argument
+	}
+	return UnknownType
+}
+
+func (c *typeChecker) expectNumeric(expression Expression, position string, t Type) {
+	if t.known() && !t.numeric() {
+		c.fail(expression, position, "expected numeric operand, got %s", t)
+	}
+}
+
+func (c *typeChecker) checkFuncCall(call *FuncCallCommon, position string) Type {
+	args := c.operands(call, position)
+
+	function, ok := call.lookup()
+	switch {
+	case !ok:
+		c.fail(call, position, "%s", UnknownFunctionError{call.name})
+		return UnknownType
+	case !function.acceptsArgs(len(args)):
+		c.fail(call, position, "%s", FunctionArityError{function.Name, function.MinArgs, function.MaxArgs, len(args)})
+		return UnknownType
+	case function.ReturnType == nil:
+		return UnknownType
+	}
+
+	return function.ReturnType(args)
+}
+
+func (c *typeChecker) checkCase(expression *CaseCommon, position string) Type {
+	index := 0
+	next := func() string {
+		index++
+		return c.child(position, index-1)
+	}
+
+	var operand Type
+	if expression.operand != nil {
+		operand = c.check(expression.operand, next())
+	}
+
+	results := []Type{}
+	for _, when := range expression.whens {
+		condition := c.check(when.condition, next())
+		if expression.operand == nil {
+			c.expect(expression, position, BooleanType, condition)
+		} else {
+			c.comparable(expression, position, operand, condition, false)
+		}
+		results = append(results, c.check(when.result, next()))
+	}
+
+	if expression.elseValue != nil {
+		results = append(results, c.check(expression.elseValue, next()))
+	}
+
+	result, conflict := unifyTypes(results)
+	if conflict != UnknownType {
+		c.fail(expression, position, "CASE branches have incompatible types %s and %s", result, conflict)
+		return UnknownType
+	}
+	return result
+}
+
+//unifyTypes returns the type of the branches of a CASE, which is FLOAT when integer and float branches
+//are mixed. Branches of unknown type are skipped. When two branches are incompatible, conflict is the
+//type of the second one.
+func unifyTypes(types []Type) (result Type, conflict Type) {
+	result = NullType
+	for _, t := range types {
+		switch {
+		case t == UnknownType, t == NullType:
+		case result == NullType || result == t:
+			result = t
+		case result.numeric() && t.numeric():
+			result = FloatType
+		default:
+			return result, t
+		}
+	}
+	return result, UnknownType
+}
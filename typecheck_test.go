@@ -0,0 +1,135 @@
+package common
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestTypeCheckInfersTypes(t *testing.T) {
+	schema := Schema{"i": IntegerType, "f": FloatType, "s": CharType, "b": BooleanType, "d": DatetimeType}
+	i, f, s := NewIdCommon("i", ""), NewIdCommon("f", ""), NewIdCommon("s", "")
+
+	tests := []struct {
+		expression Expression
+		expected   Type
+	}{
+		{NewIntCommon(1), IntegerType},
+		{NewNullCommon(), NullType},
+		{i, IntegerType},
+		{NewSumCommon(i, i), IntegerType},
+		{NewDivCommon(f, i), FloatType},
+		{NewSumCommon(NewNullCommon(), i), NullType},
+		{NewLtCommon(f, i), BooleanType},
+		{NewEqCommon(NewNullCommon(), s), BooleanType},
+		{NewLikeCommon(NewStringCommon("a%"), s), BooleanType},
+		{NewAndCommon(NewIdCommon("b", ""), NewIsNullCommon(s, false)), BooleanType},
+		{NewInCommon(i, []Expression{NewIntCommon(1), NewFloatCommon(2)}, false), BooleanType},
+		{NewBetweenCommon(NewIdCommon("d", ""), NewIdCommon("d", ""), NewIdCommon("d", ""), false, false), BooleanType},
+		{NewFuncCallCommon("length", []Expression{s}), IntegerType},
+		{NewFuncCallCommon("abs", []Expression{f}), FloatType},
+		{NewAggregateCommon("avg", i, false), FloatType},
+		{NewAggregateCommon("sum", i, false), IntegerType},
+		{NewAggregateCommon("max", s, false), CharType},
+		{NewAggregateCommon("count", nil, false), IntegerType},
+		{NewCaseCommon(nil, []*WhenCommon{NewWhenCommon(NewIdCommon("b", ""), s)}, NewNullCommon()), CharType},
+		{NewScalarSubqueryCommon(nil), UnknownType},
+	}
+
+	for _, test := range tests {
+		if result, err := TypeCheck(test.expression, schema); err != nil || result != test.expected {
+			t.Errorf("%v: expected %s, got %s (%v)", test.expression, test.expected, result, err)
+		}
+	}
+}
+
+func TestTypeCheckReportsEveryError(t *testing.T) {
+	schema := Schema{"i": IntegerType, "s": CharType}
+
+	expression := NewOrCommon(
+		NewSumCommon(NewIntCommon(1), NewIdCommon("missing", "")),
+		NewAndCommon(NewLikeCommon(NewStringCommon("a%"), NewIdCommon("i", "")), NewEqCommon(NewStringCommon("a"), NewIdCommon("i", ""))),
+	)
+
+	_, err := TypeCheck(expression, schema)
+
+	expected := TypeErrors{
+		{"$/0/0", "EqCommon", "cannot compare INTEGER with CHAR"},
+		{"$/0/1", "LikeCommon", "expected CHAR operand, got INTEGER"},
+		{"$/1/0", "IdCommon", "identifier `missing' does not exist"},
+	}
+	if !reflect.DeepEqual(err, expected) {
+		t.Errorf("expected %v, got %v", expected, err)
+	}
+}
+
+func TestTypeCheckDoesNotModifyTheExpression(t *testing.T) {
+	expression := NewCaseCommon(nil, []*WhenCommon{NewWhenCommon(NewIdCommon("x", ""), NewIntCommon(1))}, NewIdCommon("f", ""))
+	original := *expression
+
+	var wg sync.WaitGroup
+	for _, f := range []Type{FloatType, IntegerType} {
+		wg.Add(1)
+		go func(f Type) {
+			defer wg.Done()
+			if result, err := TypeCheck(expression, Schema{"x": BooleanType, "f": f}); err != nil || result != f {
+				t.Errorf("expected %s, got %s (%v)", f, result, err)
+			}
+		}(f)
+	}
+	wg.Wait()
+
+	if !reflect.DeepEqual(*expression, original) {
+		t.Errorf("expected TypeCheck to leave %v unchanged", expression)
+	}
+	if value, err := expression.EvaluateE(map[string]interface{}{"x": true, "f": nil}); err != nil || value != int64(1) {
+		t.Errorf("expected 1, got %v (%T, %v)", value, value, err)
+	}
+}
+
+func TestTypeCheckSelect(t *testing.T) {
+	schema := Schema{"t.i": IntegerType, "t.s": CharType, "u.i": IntegerType}
+	i, s := NewIdCommon("t", "i"), NewIdCommon("t", "s")
+
+	query := NewSelectTableCommand("t", "", nil,
+		[]JoinSelect{*NewJoinSelect("u", "", NewEqCommon(NewIdCommon("u", "i"), i)), *NewJoinSelect("u", "", s)},
+		NewSumCommon(i, i),
+		[]GroupBySelect{*NewGroupBySelect("t", "i")},
+		NewAndCommon(NewGtCommon(NewIntCommon(0), NewAggregateCommon("sum", s, false)), NewLtCommon(s, i)),
+		[]OrderBySelect{*NewOrderBySelect(NewSumCommon(NewIntCommon(1), s), false, NullsDefault)},
+		0, false, 0, false)
+
+	expected := TypeErrors{
+		{"WHERE", "SumCommon", "WHERE clause must be BOOLEAN, not INTEGER"},
+		{"JOIN[1]", "IdCommon", "JOIN[1] clause must be BOOLEAN, not CHAR"},
+		{"HAVING/0/1", "IdCommon", "identifier `t.s' does not exist"},
+		{"HAVING/1/0", "AggregateCommon", "expected numeric operand, got CHAR"},
+		{"HAVING/1", "GtCommon", "cannot compare CHAR with INTEGER"},
+		{"ORDER BY[0]", "SumCommon", "undefined arithmetic operator for types CHAR and INTEGER"},
+	}
+
+	if err := TypeCheckSelect(query, schema); !reflect.DeepEqual(err, expected) {
+		t.Errorf("expected %v, got %v", expected, err)
+	}
+}
+
+func TestSchemas(t *testing.T) {
+	table := NewCreateTableCommand("t", TableColumnDefiners{
+		NewIntegerTableColumn("id", nil, false, true, true, false),
+		NewCharTableColumn("name", nil, true, false, false, false, 10),
+	})
+
+	schema := NewSchema("a", table)
+	if expected := (Schema{"id": IntegerType, "a.id": IntegerType, "name": CharType, "a.name": CharType}); !reflect.DeepEqual(schema, expected) {
+		t.Errorf("expected %v, got %v", expected, schema)
+	}
+
+	merged := schema.Merge(Schema{"id": FloatType, "b.id": FloatType, "other": BooleanType})
+	if expected := (Schema{"a.id": IntegerType, "name": CharType, "a.name": CharType, "b.id": FloatType, "other": BooleanType}); !reflect.DeepEqual(merged, expected) {
+		t.Errorf("expected the ambiguous id to be left out, got %v", merged)
+	}
+
+	if _, err := TypeCheck(NewIdCommon("id", ""), merged); err == nil {
+		t.Error("expected an error for an ambiguous column")
+	}
+}
@@ -0,0 +1,73 @@
+package common
+
+//Visitor is called by Walk for every node of an expression tree. If Visit returns a non nil
+//visitor w, Walk visits the children of the node with w.
+type Visitor interface {
+	Visit(expression Expression) (w Visitor)
+}
+
+//Walk traverses an expression tree in depth-first order, starting with a call to v.Visit(expression).
+func Walk(v Visitor, expression Expression) {
+	if expression == nil {
+		return
+	}
+
+	if v = v.Visit(expression); v == nil {
+		return
+	}
+
+	for _, child := range expression.Children() {
+		Walk(v, child)
+	}
+}
+
+type inspector func(Expression) bool
+
+func (f inspector) Visit(expression Expression) Visitor {
+	if f(expression) {
+		return f
+	}
+	return nil
+}
+
+//Inspect traverses an expression tree in depth-first order, calling f for every node. The children of
+//a node are only visited when f returns true.
+func Inspect(expression Expression, f func(Expression) bool) {
+	Walk(inspector(f), expression)
+}
+
+//Rewrite returns a copy of an expression tree where every node is replaced by the result of f.
+//Children are rewritten before their parent, and the input tree is not modified.
+func Rewrite(expression Expression, f func(Expression) Expression) Expression {
+	if expression == nil {
+		return nil
+	}
+
+	children := expression.Children()
+	if len(children) > 0 {
+		rewritten := make([]Expression, len(children))
+		for i, child := range children {
+			rewritten[i] = Rewrite(child, f)
+		}
+		expression = withChildren(expression, rewritten)
+	}
+
+	return f(expression)
+}
+
+//References returns every identifier referenced by an expression tree, including those inside aggregates.
+func References(expression Expression) []*IdCommon {
+	references := []*IdCommon{}
+
+	Inspect(expression, func(e Expression) bool {
+		switch id := e.(type) {
+		case *IdCommon:
+			references = append(references, id)
+		case IdCommon:
+			references = append(references, &id)
+		}
+		return true
+	})
+
+	return references
+}
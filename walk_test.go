@@ -0,0 +1,127 @@
+package common
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//depthVisitor records every visited node, indented by its depth.
+type depthVisitor struct {
+	depth   int
+	visited *[]string
+}
+
+func (v depthVisitor) Visit(expression Expression) Visitor {
+	*v.visited = append(*v.visited, strings.Repeat(" ", v.depth)+fmt.Sprint(expression))
+	return depthVisitor{v.depth + 1, v.visited}
+}
+
+func TestWalkVisitsDepthFirst(t *testing.T) {
+	x := NewIdCommon("x", "")
+	expression := NewAndCommon(NewNotCommon(NewIsNullCommon(x, false)), NewLtCommon(NewIntCommon(1), NewSumCommon(NewIntCommon(2), x)))
+
+	visited := []string{}
+	Walk(depthVisitor{0, &visited}, expression)
+
+	expected := []string{
+		"x + 2 < 1 AND NOT x IS NULL",
+		" x + 2 < 1",
+		"  x + 2",
+		"   x",
+		"   2",
+		"  1",
+		" NOT x IS NULL",
+		"  x IS NULL",
+		"   x",
+	}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(visited, "\n"))
+	}
+
+	Walk(depthVisitor{0, &visited}, nil)
+	if len(visited) != len(expected) {
+		t.Error("expected a nil expression not to be visited")
+	}
+}
+
+func TestInspectPrunesChildren(t *testing.T) {
+	sum := NewAggregateCommon("sum", NewIdCommon("x", ""), false)
+	expression := NewGtCommon(NewIdCommon("y", ""), sum)
+
+	visited := 0
+	Inspect(expression, func(e Expression) bool {
+		visited++
+		_, aggregate := e.(*AggregateCommon)
+		return !aggregate
+	})
+
+	if visited != 3 {
+		t.Errorf("expected the argument of the aggregate not to be visited, visited %d nodes", visited)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	x := NewIdCommon("x", "")
+	expression := NewAndCommon(NewEqCommon(NewIntCommon(1), x), NewGtCommon(NewIntCommon(0), NewAggregateCommon("sum", x, false)))
+	original := fmt.Sprint(expression)
+
+	qualified := Rewrite(expression, func(e Expression) Expression {
+		if id, ok := e.(*IdCommon); ok && id.alias == "" {
+			return NewIdCommon("t", id.name)
+		}
+		return e
+	})
+
+	if rewritten := fmt.Sprint(qualified); rewritten != "SUM(t.x) > 0 AND t.x = 1" {
+		t.Errorf("expected every identifier to be qualified, got %s", rewritten)
+	}
+	if fmt.Sprint(expression) != original {
+		t.Errorf("expected the input to be kept, got %v", expression)
+	}
+
+	order := []string{}
+	Rewrite(expression, func(e Expression) Expression {
+		order = append(order, fmt.Sprint(e))
+		return e
+	})
+	if expected := []string{"x", "SUM(x)", "0", "SUM(x) > 0", "x", "1", "x = 1", original}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("expected children to be rewritten before their parent, got %v", order)
+	}
+
+	if Rewrite(nil, func(e Expression) Expression { return e }) != nil {
+		t.Error("expected a nil expression to be rewritten as nil")
+	}
+}
+
+func TestRewriteKeepsEveryNode(t *testing.T) {
+	for _, expression := range sampleExpressions() {
+		rewritten := Rewrite(expression, func(e Expression) Expression { return e })
+
+		if fmt.Sprint(rewritten) != fmt.Sprint(expression) {
+			t.Errorf("expected %v, got %v", expression, rewritten)
+		}
+		if len(References(rewritten)) != len(References(expression)) {
+			t.Errorf("%v: expected the references to be kept", expression)
+		}
+	}
+}
+
+func TestReferences(t *testing.T) {
+	expression := NewCaseCommon(NewIdCommon("a", ""), []*WhenCommon{
+		NewWhenCommon(NewIdCommon("t", "b"), NewAggregateCommon("max", NewIdCommon("c", ""), true)),
+	}, NewFuncCallCommon("abs", []Expression{NewIdCommon("a", "")}))
+
+	keys := []string{}
+	for _, id := range References(expression) {
+		keys = append(keys, id.Key())
+	}
+
+	if expected := []string{"a", "t.b", "c", "a"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %v, got %v", expected, keys)
+	}
+	if references := References(NewIntCommon(1)); len(references) != 0 {
+		t.Errorf("expected no references, got %v", references)
+	}
+}